@@ -14,18 +14,24 @@ func NewLocalAccountRetriever(provider Provider, synchronizeAccount AccountSynch
 // Retrieve validates if the given credentials are valid for the provider, if the user is valid then it creates the
 // given user account, and the federated account.
 func (a LocalAccountRetriever) Retrieve(input *InitializeAccountInput) (*InitializeAccountOutput, error) {
-	validationResult, err := a.provider.Retrieve(NewValidationInput(input.Email, input.Secret))
+	validationInput := NewValidationInput(input.Email, input.Secret)
+	validationInput.Nonce = input.Nonce
+	validationInput.FirstName = input.FirstName
+	validationInput.LastName = input.LastName
+
+	validationResult, err := a.provider.Retrieve(validationInput)
 	if err != nil {
 		return nil, err
 	}
 
 	output, err := a.synchronizeAccount.Synchronize(&SynchronizeInput{
-		Provider:  a.provider.Name(),
-		ID:        validationResult.ID,
-		FirstName: validationResult.FirstName,
-		LastName:  validationResult.LastName,
-		Email:     validationResult.Email,
-		PhotoURL:  validationResult.PhotoURL,
+		Provider:      a.provider.Name(),
+		ID:            validationResult.ID,
+		FirstName:     validationResult.FirstName,
+		LastName:      validationResult.LastName,
+		Email:         validationResult.Email,
+		EmailVerified: validationResult.EmailValidated,
+		PhotoURL:      validationResult.PhotoURL,
 	})
 	if err != nil {
 		return nil, err
@@ -34,13 +40,17 @@ func (a LocalAccountRetriever) Retrieve(input *InitializeAccountInput) (*Initial
 	return &InitializeAccountOutput{
 		NewUser:    output.NewUser,
 		NewAccount: output.NewAccount,
+		Provider:   a.provider.Name(),
 		Customer: &CustomerAccount{
-			ID:        output.CustomerID,
-			Email:     validationResult.Email,
-			Name:      fmt.Sprintf("%s %s", validationResult.FirstName, validationResult.LastName),
-			FirstName: validationResult.FirstName,
-			LastName:  validationResult.LastName,
-			PhotoURL:  validationResult.PhotoURL,
+			ID:            output.CustomerID,
+			Email:         validationResult.Email,
+			EmailVerified: validationResult.EmailValidated,
+			Name:          fmt.Sprintf("%s %s", output.FirstName, output.LastName),
+			FirstName:     output.FirstName,
+			LastName:      output.LastName,
+			PhotoURL:      validationResult.PhotoURL,
+			PrivateEmail:  validationResult.PrivateEmail,
+			Username:      validationResult.Username,
 		},
 	}, nil
 }
@@ -78,7 +78,7 @@ func TestLocalAccountHandler_Register(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.fields.provider.On("Name").Return(tt.args.providerName)
-			tt.fields.provider.On("Retrieve", &ValidationInput{tt.args.input.Email, tt.args.input.Secret}).Return(&ValidationOutput{
+			tt.fields.provider.On("Retrieve", &ValidationInput{Email: tt.args.input.Email, Secret: tt.args.input.Secret}).Return(&ValidationOutput{
 				ID:        tt.args.providerReference,
 				FirstName: tt.args.firstName,
 				LastName:  tt.args.lastName,
@@ -0,0 +1,61 @@
+package authentication_pool
+
+import "strings"
+
+const (
+	AppleIssuer  = "https://appleid.apple.com"
+	appleKeysURL = AppleIssuer + "/auth/keys"
+	// applePrivateRelayDomain is the domain of the addresses created by "Hide My Email".
+	applePrivateRelayDomain = "@privaterelay.appleid.com"
+)
+
+// AppleProvider validates the id_tokens of Sign in with Apple. Apple only sends the name of the user in the first
+// authorization and out of the token, so the client must send it in the ValidationInput.
+type AppleProvider struct {
+	oidc *OIDCProvider
+}
+
+// NewAppleProvider creates a provider that accepts the id_tokens issued to the given clients, they are the bundle IDs
+// of the apps and the services IDs of the websites.
+func NewAppleProvider(clientIDs []string, opts ...OIDCProviderOptions) (*AppleProvider, error) {
+	oidc, err := NewOIDCProvider("apple", AppleIssuer, clientIDs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	oidc.jwksURI = appleKeysURL
+	return &AppleProvider{oidc: oidc}, nil
+}
+
+func (a *AppleProvider) Retrieve(input *ValidationInput) (*ValidationOutput, error) {
+	claims, err := a.oidc.verify(input.Secret, input.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	output := a.oidc.validationOutput(claims)
+	output.FirstName, output.LastName = input.FirstName, input.LastName
+	output.PrivateEmail = isApplePrivateEmail(claims.Email, claims.Extra["is_private_email"])
+
+	return output, nil
+}
+
+func (a *AppleProvider) Name() string {
+	return a.oidc.Name()
+}
+
+// isApplePrivateEmail tells if the email is a relay address, Apple sends the claim as a string or as a boolean.
+func isApplePrivateEmail(email string, claim interface{}) bool {
+	switch v := claim.(type) {
+	case bool:
+		if v {
+			return true
+		}
+	case string:
+		if v == "true" {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(strings.ToLower(email), applePrivateRelayDomain)
+}
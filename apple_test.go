@@ -0,0 +1,106 @@
+package authentication_pool
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/pascaldekloe/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAppleProvider_Retrieve(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC", "crv": "P-256", "kid": "apple-key", "alg": "ES256", "use": "sig",
+			"x": base64.RawURLEncoding.EncodeToString(padBytes(privateKey.X.Bytes(), 32)),
+			"y": base64.RawURLEncoding.EncodeToString(padBytes(privateKey.Y.Bytes(), 32)),
+		}}})
+	}))
+	defer server.Close()
+
+	a, err := NewAppleProvider([]string{"com.example.app"})
+	if err != nil {
+		t.Fatalf("NewAppleProvider() error = %v", err)
+	}
+	a.oidc.jwksURI = server.URL
+
+	idToken := func(audience string, set map[string]interface{}) string {
+		now := time.Now().Truncate(time.Second)
+		claims := &jwt.Claims{
+			Registered: jwt.Registered{
+				Issuer:    AppleIssuer,
+				Subject:   "001234.abcdef",
+				Audiences: []string{audience},
+				Issued:    jwt.NewNumericTime(now),
+				Expires:   jwt.NewNumericTime(now.Add(time.Minute * 10)),
+			},
+			KeyID: "apple-key",
+			Set:   set,
+		}
+
+		token, err := claims.ECDSASign(jwt.ES256, privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(token)
+	}
+
+	t.Run("first authorization", func(t *testing.T) {
+		token := idToken("com.example.app", map[string]interface{}{
+			"nonce": "nonce-1", "email": "x7k2@privaterelay.appleid.com", "email_verified": "true", "is_private_email": "true",
+		})
+
+		got, err := a.Retrieve(&ValidationInput{Secret: token, Nonce: "nonce-1", FirstName: "Jane", LastName: "Doe"})
+		if err != nil {
+			t.Fatalf("Retrieve() error = %v", err)
+		}
+
+		if got.ID != "001234.abcdef" || got.FirstName != "Jane" || got.LastName != "Doe" || !got.EmailValidated || !got.PrivateEmail {
+			t.Errorf("Retrieve() got = %+v", got)
+		}
+	})
+
+	t.Run("public email", func(t *testing.T) {
+		token := idToken("com.example.app", map[string]interface{}{"nonce": "nonce-1", "email": "jane@example.com", "email_verified": true})
+
+		got, err := a.Retrieve(&ValidationInput{Secret: token, Nonce: "nonce-1"})
+		if err != nil {
+			t.Fatalf("Retrieve() error = %v", err)
+		}
+
+		if got.PrivateEmail || got.FirstName != "" {
+			t.Errorf("Retrieve() got = %+v", got)
+		}
+	})
+
+	t.Run("another nonce", func(t *testing.T) {
+		token := idToken("com.example.app", map[string]interface{}{"nonce": "nonce-1"})
+		if _, err := a.Retrieve(&ValidationInput{Secret: token, Nonce: "nonce-2"}); err == nil {
+			t.Errorf("Retrieve() error = nil")
+		}
+	})
+
+	t.Run("without nonce", func(t *testing.T) {
+		token := idToken("com.example.app", map[string]interface{}{"nonce": "nonce-1"})
+		if _, err := a.Retrieve(&ValidationInput{Secret: token}); err == nil {
+			t.Errorf("Retrieve() error = nil")
+		}
+	})
+
+	t.Run("another application", func(t *testing.T) {
+		token := idToken("com.another.app", map[string]interface{}{"nonce": "nonce-1"})
+		if _, err := a.Retrieve(&ValidationInput{Secret: token, Nonce: "nonce-1"}); err == nil {
+			t.Errorf("Retrieve() error = nil")
+		}
+	})
+}
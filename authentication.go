@@ -11,8 +11,11 @@ func NewAuthenticationPoolProvider(tokenProvider TokenProvider, localCustomerReg
 
 func (a AuthenticationPoolProvider) Authenticate(handler AccountRetriever, input *AuthenticateInput) (*AuthenticateOutput, error) {
 	output, err := handler.Retrieve(&InitializeAccountInput{
-		Email:  input.Email,
-		Secret: input.Secret,
+		Email:     input.Email,
+		Secret:    input.Secret,
+		Nonce:     input.Nonce,
+		FirstName: input.FirstName,
+		LastName:  input.LastName,
 	})
 
 	if err != nil {
@@ -20,19 +23,24 @@ func (a AuthenticationPoolProvider) Authenticate(handler AccountRetriever, input
 	}
 
 	account := output.Customer
-	_, err = a.validateAccount(account.Email)
+	_, err = a.validateAccount(a.localCustomerRegister.Find(&FindLocalAccountInput{Email: account.Email}))
 	if err != nil {
 		return nil, err
 	}
 
 	tokens, err := a.tokenProvider.CreateToken(&CreateTokenInput{
-		ID:            account.ID,
-		Name:          account.Name,
-		GivenName:     account.FirstName,
-		FamilyName:    account.LastName,
-		Email:         account.Email,
-		EmailVerified: account.EmailVerified,
-		Picture:       account.PhotoURL,
+		ID:             account.ID,
+		Name:           account.Name,
+		GivenName:      account.FirstName,
+		FamilyName:     account.LastName,
+		Email:          account.Email,
+		EmailVerified:  account.EmailVerified,
+		Picture:        account.PhotoURL,
+		UserAgent:      input.UserAgent,
+		IPAddress:      input.IPAddress,
+		Provider:       output.Provider,
+		DeviceClass:    input.DeviceClass,
+		DPoPThumbprint: input.DPoPThumbprint,
 	})
 
 	if err != nil {
@@ -58,16 +66,16 @@ func (a AuthenticationPoolProvider) Verify(input string) (*AuthenticationVerifyO
 		return nil, ErrInvalidToken
 	}
 
-	customer, err := a.validateAccount(*output.CustomerEmail)
+	customer, err := a.validateAccount(a.localCustomerRegister.FindByID(&FindLocalAccountByIDInput{ID: output.Subject}))
 	if err != nil {
 		return nil, err
 	}
 
-	return &AuthenticationVerifyOutput{Account: customer}, nil
+	return &AuthenticationVerifyOutput{Account: customer, Claims: output.Claims}, nil
 }
 
-func (a AuthenticationPoolProvider) validateAccount(email string) (*LocalAccount, error) {
-	customer, err := a.localCustomerRegister.Find(&FindLocalAccountInput{Email: email})
+// validateAccount takes the result of a lookup and checks that the account exists and it is enabled.
+func (a AuthenticationPoolProvider) validateAccount(customer *LocalAccount, err error) (*LocalAccount, error) {
 	if err != nil {
 		return nil, err
 	}
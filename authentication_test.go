@@ -3,6 +3,7 @@ package authentication_pool
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestAuthenticationPoolProvider_Authenticate(t *testing.T) {
@@ -37,4 +38,107 @@ func TestAuthenticationPoolProvider_Authenticate(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// newTestAuthentication returns an authentication with a local user whose email is first@example.com.
+func newTestAuthentication(t *testing.T, tokenProvider TokenProvider) (*AuthenticationPoolProvider, AccountRetriever, string) {
+	customers := NewInMemoryCustomerRepository(UUIDGenerator)
+	synchronization := NewLocalSynchronization(customers, NewInMemoryFederatedAccountRepository())
+	localProvider, err := NewLocalProvider(NewInMemoryLocalAPI(UUIDGenerator), synchronization)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signUp, err := localProvider.SignUp(&SignUpInput{Email: "first@example.com", Secret: "aA123456%", Validated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewAuthenticationPoolProvider(tokenProvider, customers), NewLocalAccountRetriever(localProvider, synchronization), signUp.ID
+}
+
+func TestAuthenticationPoolProvider_AuthenticateDeviceClass(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	tokenProvider := newTestJWTTokenProvider(issuedAt, issuedAt)
+	tokenProvider.sessionLimit = &SessionLimitPolicy{MaxSessionsPerDeviceClass: map[string]int{"mobile": 1}}
+	a, retriever, customerID := newTestAuthentication(t, tokenProvider)
+
+	input := &AuthenticateInput{Email: "first@example.com", Secret: "aA123456%", DeviceClass: "mobile"}
+	if _, err := a.Authenticate(retriever, input); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	sessions, err := tokenProvider.Sessions(customerID)
+	if err != nil || len(sessions) != 1 || sessions[0].DeviceClass != "mobile" {
+		t.Fatalf("Sessions() got = %v, error = %v, want a mobile session", sessions, err)
+	}
+
+	if _, err = a.Authenticate(retriever, input); err != ErrSessionLimitReached {
+		t.Errorf("Authenticate() in another mobile error = %v, want %v", err, ErrSessionLimitReached)
+	}
+}
+
+func TestAuthenticationPoolProvider_AuthenticateDPoP(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	tokenProvider := newTestJWTTokenProvider(issuedAt, issuedAt)
+	a, retriever, _ := newTestAuthentication(t, tokenProvider)
+
+	client := newTestDPoPClient()
+	input := &AuthenticateInput{Email: "first@example.com", Secret: "aA123456%", DPoPThumbprint: client.thumbprint()}
+	output, err := a.Authenticate(retriever, input)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	got, err := tokenProvider.Introspect(&IntrospectTokenInput{Token: output.AccessToken.Content})
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+
+	if got.TokenType != "DPoP" || got.Confirmation == nil || got.Confirmation.JWKThumbprint != client.thumbprint() {
+		t.Errorf("Introspect() got = %v, want a token bound to the key of the client", got)
+	}
+}
+
+func TestAuthenticationPoolProvider_Verify(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	tokenProvider := newTestJWTTokenProvider(issuedAt, issuedAt)
+	customers := NewInMemoryCustomerRepository(UUIDGenerator)
+	a := NewAuthenticationPoolProvider(tokenProvider, customers)
+
+	customer, err := customers.Create(&CreateLocalAccountInput{Email: "first@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("resolves the account by the subject", func(t *testing.T) {
+		tokens, err := tokenProvider.CreateToken(&CreateTokenInput{ID: customer.ID, Email: "changed@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := a.Verify(tokens.AccessToken.Content)
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+
+		if !reflect.DeepEqual(got.Account, customer) {
+			t.Errorf("Verify() got account = %v, want %v", got.Account, customer)
+		}
+
+		if got.Claims == nil || got.Claims.RegisteredClaims.Subject != customer.ID || got.Claims.PublicClaims.Email != "changed@example.com" {
+			t.Errorf("Verify() got claims = %v", got.Claims)
+		}
+	})
+
+	t.Run("rejects unknown subjects", func(t *testing.T) {
+		tokens, err := tokenProvider.CreateToken(&CreateTokenInput{ID: "unknown", Email: "first@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = a.Verify(tokens.AccessToken.Content); err == nil {
+			t.Errorf("Verify() error = nil")
+		}
+	})
+}
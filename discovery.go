@@ -0,0 +1,220 @@
+package authentication_pool
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	OpenIDConfigurationPath = "/.well-known/openid-configuration"
+	JWKSPath                = "/.well-known/jwks.json"
+)
+
+// JSONWebKey is the public part of a SigningKey as defined by RFC 7517.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []*JSONWebKey `json:"keys"`
+}
+
+// KeySetPublisher returns the keys that third parties need to verify the tokens.
+type KeySetPublisher interface {
+	JWKS() (*JSONWebKeySet, error)
+}
+
+// NewJSONWebKey returns the public representation of the given key. HMAC keys are secret, they cannot be published.
+func NewJSONWebKey(key *SigningKey) (*JSONWebKey, error) {
+	result := &JSONWebKey{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+
+	switch publicKey := key.PublicKey.(type) {
+	case ed25519.PublicKey:
+		result.KeyType = "OKP"
+		result.Curve = "Ed25519"
+		result.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		result.KeyType = "EC"
+		result.Curve = publicKey.Curve.Params().Name
+		result.X = base64.RawURLEncoding.EncodeToString(padBytes(publicKey.X.Bytes(), size))
+		result.Y = base64.RawURLEncoding.EncodeToString(padBytes(publicKey.Y.Bytes(), size))
+	case *rsa.PublicKey:
+		result.KeyType = "RSA"
+		result.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		result.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	default:
+		return nil, errors.New("the given key cannot be published")
+	}
+
+	return result, nil
+}
+
+func padBytes(content []byte, size int) []byte {
+	if len(content) >= size {
+		return content
+	}
+
+	return append(make([]byte, size-len(content)), content...)
+}
+
+// JWKS returns the keys that can verify tokens. The HMAC keys are skipped.
+func (k *Keyring) JWKS() (*JSONWebKeySet, error) {
+	result := &JSONWebKeySet{Keys: make([]*JSONWebKey, 0)}
+	for _, key := range k.Keys() {
+		if _, isSecret := key.PublicKey.([]byte); isSecret {
+			continue
+		}
+
+		jwk, err := NewJSONWebKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Keys = append(result.Keys, jwk)
+	}
+
+	return result, nil
+}
+
+func (p PascalDeKloeJWTHandler) JWKS() (*JSONWebKeySet, error) {
+	return p.keyring.JWKS()
+}
+
+// AuthorizationEndpoint publishes the endpoint where the application serves the authorization requests, along with
+// the response types it supports, like "code". Without it the discovery document only describes the tokens.
+func AuthorizationEndpoint(endpoint string, responseTypes ...string) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if location, err := url.Parse(endpoint); err != nil || !location.IsAbs() {
+			return errors.New("the authorization endpoint must be an absolute URL")
+		}
+
+		if len(responseTypes) == 0 {
+			return errors.New("the authorization endpoint needs the supported response types")
+		}
+
+		provider.authorizationEndpoint = endpoint
+		provider.responseTypes = responseTypes
+		return nil
+	}
+}
+
+// OpenIDConfiguration is the discovery document defined by OpenID Connect Discovery 1.0. The authorization endpoint
+// and the response types are omitted when the application does not have an authorization endpoint.
+type OpenIDConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint,omitempty"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	Audience                         []string `json:"audience,omitempty"`
+	ResponseTypesSupported           []string `json:"response_types_supported,omitempty"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// OpenIDConfiguration builds the discovery document of the provider. The issuer must be the URL where the document is
+// served.
+func (j JWTTokenProvider) OpenIDConfiguration(keys KeySetPublisher) (*OpenIDConfiguration, error) {
+	jwks, err := keys.JWKS()
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := make([]string, 0)
+	seen := map[string]bool{}
+	for _, key := range jwks.Keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+
+	return &OpenIDConfiguration{
+		Issuer:                           j.issuer,
+		AuthorizationEndpoint:            j.authorizationEndpoint,
+		JWKSURI:                          strings.TrimSuffix(j.issuer, "/") + JWKSPath,
+		Audience:                         j.audience,
+		ResponseTypesSupported:           j.responseTypes,
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: algorithms,
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "name", "given_name", "family_name", "email",
+			"email_verified", "picture", "phone_number", "phone_number_verified",
+		},
+	}, nil
+}
+
+// DiscoveryHandler serves the OpenID discovery document and the JWKS of the provider.
+type DiscoveryHandler struct {
+	provider *JWTTokenProvider
+	keys     KeySetPublisher
+	maxAge   time.Duration
+}
+
+// NewDiscoveryHandler creates the handler, the responses can be cached by the clients during maxAge. The maxAge must
+// be shorter than the time between adding a signing key and activating it.
+func NewDiscoveryHandler(provider *JWTTokenProvider, keys KeySetPublisher, maxAge time.Duration) *DiscoveryHandler {
+	return &DiscoveryHandler{provider: provider, keys: keys, maxAge: maxAge}
+}
+
+func (d *DiscoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		document interface{}
+		err      error
+	)
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, OpenIDConfigurationPath):
+		document, err = d.provider.OpenIDConfiguration(d.keys)
+	case strings.HasSuffix(r.URL.Path, JWKSPath):
+		document, err = d.keys.JWKS()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := fmt.Sprintf(`"%s"`, base64.RawURLEncoding.EncodeToString(sum[:16]))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(d.maxAge/time.Second)))
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
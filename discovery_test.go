@@ -0,0 +1,134 @@
+package authentication_pool
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/pascaldekloe/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryHandler_ServeHTTP(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	keyring := NewKeyring(time.Hour)
+	rsaSigningKey, _ := NewSigningKey("rsa", "RS256", nil, rsaKey)
+	secretKey, _ := NewSigningKey("secret", "HS256", nil, []byte("a secret that is long enough for HS256"))
+	_ = keyring.Add(newTestSigningKey("ed25519"))
+	_ = keyring.Add(rsaSigningKey)
+	_ = keyring.Add(secretKey)
+	_ = keyring.Activate(rsaSigningKey.ID)
+
+	jwtHandler := NewPascalDeKloeJWTHandlerWithKeyring(keyring, time.Minute, 0)
+	provider, err := NewJWTTokenProvider("https://auth.example.com", []string{"app"}, jwtHandler, NewObscureUUIDTokenHandler(), NewInMemoryTokenPersistence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewDiscoveryHandler(provider, jwtHandler, time.Minute*5)
+
+	t.Run("serves the JWKS", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("ServeHTTP() status = %v", recorder.Code)
+		}
+		if got := recorder.Header().Get("Cache-Control"); got != "public, max-age=300" {
+			t.Errorf("ServeHTTP() Cache-Control = %v", got)
+		}
+
+		jwks := &JSONWebKeySet{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), jwks); err != nil {
+			t.Fatal(err)
+		}
+		if len(jwks.Keys) != 2 {
+			t.Errorf("ServeHTTP() keys = %v, want the Ed25519 and the RSA keys", len(jwks.Keys))
+		}
+
+		token, err := jwtHandler.Issue(&IssueInput{RegisteredClaims: RegisteredClaims{Subject: "customer"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		register := &jwt.KeyRegister{}
+		if _, err = register.LoadJWK(recorder.Body.Bytes()); err != nil {
+			t.Fatalf("LoadJWK() error = %v", err)
+		}
+		if _, err = register.Check([]byte(token.Token.Content)); err != nil {
+			t.Errorf("Check() error = %v", err)
+		}
+
+		cached := httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+		cached.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, cached)
+		if recorder.Code != http.StatusNotModified {
+			t.Errorf("ServeHTTP() status = %v, want %v", recorder.Code, http.StatusNotModified)
+		}
+	})
+
+	t.Run("serves the OpenID configuration", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, OpenIDConfigurationPath, nil))
+
+		configuration := &OpenIDConfiguration{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), configuration); err != nil {
+			t.Fatal(err)
+		}
+
+		if configuration.Issuer != "https://auth.example.com" {
+			t.Errorf("ServeHTTP() issuer = %v", configuration.Issuer)
+		}
+		if configuration.JWKSURI != "https://auth.example.com/.well-known/jwks.json" {
+			t.Errorf("ServeHTTP() jwks_uri = %v", configuration.JWKSURI)
+		}
+		if len(configuration.IDTokenSigningAlgValuesSupported) != 2 {
+			t.Errorf("ServeHTTP() algorithms = %v", configuration.IDTokenSigningAlgValuesSupported)
+		}
+		if configuration.AuthorizationEndpoint != "" || configuration.ResponseTypesSupported != nil {
+			t.Errorf("ServeHTTP() got the authorization of a provider without authorization endpoint")
+		}
+	})
+
+	t.Run("publishes the authorization endpoint", func(t *testing.T) {
+		provider, err := NewJWTTokenProvider("https://auth.example.com", []string{"app"}, jwtHandler, NewObscureUUIDTokenHandler(),
+			NewInMemoryTokenPersistence(), AuthorizationEndpoint("https://auth.example.com/authorize", "code"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		configuration, err := provider.OpenIDConfiguration(jwtHandler)
+		if err != nil {
+			t.Fatalf("OpenIDConfiguration() error = %v", err)
+		}
+
+		if configuration.AuthorizationEndpoint != "https://auth.example.com/authorize" ||
+			len(configuration.ResponseTypesSupported) != 1 || configuration.ResponseTypesSupported[0] != "code" {
+			t.Errorf("OpenIDConfiguration() got = %v", configuration)
+		}
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, JWKSPath, nil))
+		if recorder.Code != http.StatusMethodNotAllowed {
+			t.Errorf("ServeHTTP() status = %v, want %v", recorder.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func TestAuthorizationEndpoint(t *testing.T) {
+	if err := AuthorizationEndpoint("/authorize", "code")(&JWTTokenProvider{}); err == nil {
+		t.Errorf("AuthorizationEndpoint() with a relative URL error = nil")
+	}
+
+	if err := AuthorizationEndpoint("https://auth.example.com/authorize")(&JWTTokenProvider{}); err == nil {
+		t.Errorf("AuthorizationEndpoint() without response types error = nil")
+	}
+}
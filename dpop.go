@@ -0,0 +1,229 @@
+package authentication_pool
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/lapix-com-co/authentication-pool/verifier"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DPoPVerifier checks the access tokens bound to a client key, as defined by RFC 9449.
+type DPoPVerifier interface {
+	// VerifyDPoP takes an access token and the DPoP proof sent along with it. The proof must be signed by the key the
+	// token is bound to, and it must have been created for the request.
+	VerifyDPoP(input *VerifyDPoPInput) (*VerifyTokenOutput, error)
+}
+
+var _ DPoPVerifier = &JWTTokenProvider{}
+
+type VerifyDPoPInput struct {
+	AccessToken string
+	// Proof is the content of the DPoP header.
+	Proof string
+	// Method and URL are the HTTP method and the URL of the request.
+	Method string
+	URL    string
+}
+
+// EnableDPoP accepts the DPoP proofs created during the given lifetime. The proofs are remembered in memory to reject
+// the replays, the servers behind a load balancer must share the traffic of a client or accept that the replays can
+// reach another server.
+func EnableDPoP(proofLifetime time.Duration) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if proofLifetime <= 0 {
+			return errors.New("the DPoP proofs need a lifetime")
+		}
+
+		provider.dpopProofs = newDPoPReplayCache(proofLifetime)
+		return nil
+	}
+}
+
+// VerifyDPoP verifies the token and its proof. The tokens that are not bound to a key are accepted without proof.
+func (j JWTTokenProvider) VerifyDPoP(input *VerifyDPoPInput) (*VerifyTokenOutput, error) {
+	if j.dpopProofs == nil {
+		return nil, errors.New("the provider does not accept DPoP proofs")
+	}
+
+	result, err := j.verify(input.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.RegisteredClaims.Confirmation != nil {
+		if err = j.checkDPoPProof(input, result.RegisteredClaims.Confirmation.JWKThumbprint); err != nil {
+			return nil, err
+		}
+	}
+
+	return newVerifyTokenOutput(result), nil
+}
+
+type dpopProofHeader struct {
+	Type      string          `json:"typ"`
+	Algorithm string          `json:"alg"`
+	Key       json.RawMessage `json:"jwk"`
+}
+
+func (j JWTTokenProvider) checkDPoPProof(input *VerifyDPoPInput, thumbprint string) error {
+	parts := strings.Split(input.Proof, ".")
+	if len(parts) != 3 {
+		return ErrInvalidDPoPProof
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidDPoPProof
+	}
+
+	header := &dpopProofHeader{}
+	if err = json.Unmarshal(content, header); err != nil || header.Type != "dpop+jwt" {
+		return ErrInvalidDPoPProof
+	}
+
+	given, err := JWKThumbprint(header.Key)
+	if err != nil || subtle.ConstantTimeCompare([]byte(given), []byte(thumbprint)) != 1 {
+		return ErrInvalidDPoPProof
+	}
+
+	key, err := verifier.ParseJSONWebKey(header.Key)
+	if err != nil || validateKey(header.Algorithm, key.PublicKey, nil) != nil {
+		return ErrInvalidDPoPProof
+	}
+
+	claims, err := check([]byte(input.Proof), &SigningKey{Algorithm: header.Algorithm, PublicKey: key.PublicKey})
+	if err != nil {
+		return ErrInvalidDPoPProof
+	}
+
+	if stringValue(claims.Set, "htm") != input.Method || !sameTargetURI(stringValue(claims.Set, "htu"), input.URL) {
+		return ErrInvalidDPoPProof
+	}
+
+	sum := sha256.Sum256([]byte(input.AccessToken))
+	if stringValue(claims.Set, "ath") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		return ErrInvalidDPoPProof
+	}
+
+	if claims.Issued == nil || claims.ID == "" {
+		return ErrInvalidDPoPProof
+	}
+
+	now := j.timeProvider()
+	expiredAt := claims.Issued.Time().Add(j.dpopProofs.lifetime + j.leeway)
+	if claims.Issued.Time().After(now.Add(j.leeway)) || !expiredAt.After(now) {
+		return ErrInvalidDPoPProof
+	}
+
+	if !j.dpopProofs.add(claims.ID, now, expiredAt) {
+		return ErrInvalidDPoPProof
+	}
+
+	return nil
+}
+
+// sameTargetURI compares the URIs without the query and the fragment, as defined by RFC 9449.
+func sameTargetURI(given, expected string) bool {
+	a, err := url.Parse(given)
+	if err != nil {
+		return false
+	}
+
+	b, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host) && a.EscapedPath() == b.EscapedPath()
+}
+
+// JWKThumbprint returns the RFC 7638 thumbprint of the given public JSON Web Key, it is the value of
+// CreateTokenInput.DPoPThumbprint. The keys with private members are rejected.
+func JWKThumbprint(jwk []byte) (string, error) {
+	members := map[string]interface{}{}
+	if err := json.Unmarshal(jwk, &members); err != nil {
+		return "", err
+	}
+
+	if _, private := members["d"]; private {
+		return "", errors.New("the given key is a private key")
+	}
+
+	var required []string
+	switch members["kty"] {
+	case "EC":
+		required = []string{"crv", "kty", "x", "y"}
+	case "OKP":
+		required = []string{"crv", "kty", "x"}
+	case "RSA":
+		required = []string{"e", "kty", "n"}
+	default:
+		return "", errors.New("the key type is not supported")
+	}
+
+	// The required members are written in lexicographic order, without spaces.
+	canonical := make([]string, 0, len(required))
+	for _, name := range required {
+		value, ok := members[name].(string)
+		if !ok {
+			return "", errors.New("the key does not have the member " + name)
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+
+		canonical = append(canonical, `"`+name+`":`+string(encoded))
+	}
+
+	sum := sha256.Sum256([]byte("{" + strings.Join(canonical, ",") + "}"))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// dpopReplayCache remembers the jti of the proofs until they cannot be used anymore. The old proofs are pruned at most
+// once per lifetime, so the cost of the pruning is shared by all the proofs received in between.
+type dpopReplayCache struct {
+	lifetime time.Duration
+	seen     map[string]time.Time
+	prunedAt time.Time
+	mx       sync.Mutex
+}
+
+func newDPoPReplayCache(lifetime time.Duration) *dpopReplayCache {
+	return &dpopReplayCache{lifetime: lifetime, seen: map[string]time.Time{}}
+}
+
+// add returns false when the jti has been seen already. The jti is kept until the proof expires.
+func (d *dpopReplayCache) add(jti string, now, expiredAt time.Time) bool {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	if !now.Before(d.prunedAt.Add(d.lifetime)) {
+		d.prune(now)
+	}
+
+	// The expired proofs that have not been pruned yet are not replays.
+	if seenExpiredAt, ok := d.seen[jti]; ok && now.Before(seenExpiredAt) {
+		return false
+	}
+
+	d.seen[jti] = expiredAt
+	return true
+}
+
+func (d *dpopReplayCache) prune(now time.Time) {
+	for id, expiredAt := range d.seen {
+		if !now.Before(expiredAt) {
+			delete(d.seen, id)
+		}
+	}
+
+	d.prunedAt = now
+}
@@ -0,0 +1,175 @@
+package authentication_pool
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pascaldekloe/jwt"
+	"testing"
+	"time"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	// The example of RFC 7638, section 3.1.
+	jwk := `{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`
+
+	got, err := JWKThumbprint([]byte(jwk))
+	if err != nil {
+		t.Fatalf("JWKThumbprint() error = %v", err)
+	}
+
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("JWKThumbprint() got = %v, want %v", got, want)
+	}
+
+	if _, err = JWKThumbprint([]byte(`{"kty":"OKP","crv":"Ed25519","x":"AA","d":"AA"}`)); err == nil {
+		t.Errorf("JWKThumbprint() of a private key error = nil")
+	}
+}
+
+type testDPoPClient struct {
+	privateKey ed25519.PrivateKey
+	jwk        json.RawMessage
+}
+
+func newTestDPoPClient() *testDPoPClient {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	jwk, _ := json.Marshal(map[string]string{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(publicKey)})
+	return &testDPoPClient{privateKey: privateKey, jwk: jwk}
+}
+
+func (c *testDPoPClient) thumbprint() string {
+	thumbprint, err := JWKThumbprint(c.jwk)
+	if err != nil {
+		panic(err)
+	}
+
+	return thumbprint
+}
+
+func (c *testDPoPClient) proof(jti, method, url, accessToken string, issuedAt time.Time) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	claims := &jwt.Claims{
+		Registered: jwt.Registered{ID: jti, Issued: jwt.NewNumericTime(issuedAt)},
+		Set:        map[string]interface{}{"htm": method, "htu": url, "ath": base64.RawURLEncoding.EncodeToString(sum[:])},
+	}
+
+	header, _ := json.Marshal(map[string]interface{}{"typ": "dpop+jwt", "jwk": c.jwk})
+	proof, err := claims.EdDSASign(c.privateKey, header)
+	if err != nil {
+		panic(err)
+	}
+
+	return string(proof)
+}
+
+func TestJWTTokenProvider_VerifyDPoP(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt)
+	j.dpopProofs = newDPoPReplayCache(time.Minute)
+	client := newTestDPoPClient()
+
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer", DPoPThumbprint: client.thumbprint()})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	accessToken := tokens.AccessToken.Content
+
+	if _, err = j.Verify(accessToken); err != ErrDPoPProofRequired {
+		t.Errorf("Verify() error = %v, want %v", err, ErrDPoPProofRequired)
+	}
+
+	valid := &VerifyDPoPInput{
+		AccessToken: accessToken,
+		Proof:       client.proof("proof-1", "GET", "https://api.example.com/orders", accessToken, issuedAt),
+		Method:      "GET",
+		URL:         "https://api.example.com/orders?page=2",
+	}
+	if got, err := j.VerifyDPoP(valid); err != nil || !got.Valid {
+		t.Fatalf("VerifyDPoP() got = %v, error = %v", got, err)
+	}
+
+	tests := []struct {
+		name  string
+		input *VerifyDPoPInput
+	}{
+		{name: "replayed proof", input: valid},
+		{
+			name: "another method",
+			input: &VerifyDPoPInput{AccessToken: accessToken, Method: "POST", URL: "https://api.example.com/orders",
+				Proof: client.proof("proof-2", "GET", "https://api.example.com/orders", accessToken, issuedAt)},
+		},
+		{
+			name: "another URL",
+			input: &VerifyDPoPInput{AccessToken: accessToken, Method: "GET", URL: "https://api.example.com/payments",
+				Proof: client.proof("proof-3", "GET", "https://api.example.com/orders", accessToken, issuedAt)},
+		},
+		{
+			name: "old proof",
+			input: &VerifyDPoPInput{AccessToken: accessToken, Method: "GET", URL: "https://api.example.com/orders",
+				Proof: client.proof("proof-4", "GET", "https://api.example.com/orders", accessToken, issuedAt.Add(-time.Minute*2))},
+		},
+		{
+			name: "another access token",
+			input: &VerifyDPoPInput{AccessToken: accessToken, Method: "GET", URL: "https://api.example.com/orders",
+				Proof: client.proof("proof-5", "GET", "https://api.example.com/orders", "another token", issuedAt)},
+		},
+		{
+			name: "another key",
+			input: &VerifyDPoPInput{AccessToken: accessToken, Method: "GET", URL: "https://api.example.com/orders",
+				Proof: newTestDPoPClient().proof("proof-6", "GET", "https://api.example.com/orders", accessToken, issuedAt)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := j.VerifyDPoP(tt.input); err != ErrInvalidDPoPProof {
+				t.Errorf("VerifyDPoP() error = %v, want %v", err, ErrInvalidDPoPProof)
+			}
+		})
+	}
+
+	t.Run("keeps the binding after a refresh", func(t *testing.T) {
+		j.timeProvider = mockTimeProvider{issuedAt.Add(time.Hour)}.Now
+		refreshed, err := j.Refresh(&RefreshTokenInput{RefreshToken: tokens.RefreshToken.Token, AccessToken: accessToken})
+		if err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+
+		claims, _ := j.jwtHandler.Verify(&VerifyInput{refreshed.AccessToken.Content})
+		if claims.RegisteredClaims.Confirmation == nil || claims.RegisteredClaims.Confirmation.JWKThumbprint != client.thumbprint() {
+			t.Errorf("Refresh() got confirmation = %v", claims.RegisteredClaims.Confirmation)
+		}
+	})
+}
+
+func Test_dpopReplayCache_add(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	cache := newDPoPReplayCache(time.Minute)
+
+	if !cache.add("proof-1", now, now.Add(time.Minute)) {
+		t.Fatalf("add() of a new proof = false")
+	}
+
+	if cache.add("proof-1", now.Add(time.Second*30), now.Add(time.Minute)) {
+		t.Errorf("add() of a replayed proof = true")
+	}
+
+	// The entries are not pruned before the lifetime, but the expired proofs are accepted.
+	cache.add("proof-2", now.Add(time.Second*10), now.Add(time.Second*20))
+	if !cache.add("proof-2", now.Add(time.Second*30), now.Add(time.Second*90)) {
+		t.Errorf("add() of an expired proof = false")
+	}
+	if len(cache.seen) != 2 {
+		t.Errorf("add() pruned before the lifetime, got %d entries", len(cache.seen))
+	}
+
+	cache.add("proof-3", now.Add(time.Minute*2), now.Add(time.Minute*3))
+	if len(cache.seen) != 1 {
+		t.Errorf("add() did not prune the expired proofs, got %d entries", len(cache.seen))
+	}
+}
@@ -47,10 +47,20 @@ func init() {
 		panic(err)
 	}
 
+	keyring := NewKeyring(time.Hour)
+	signingKey, err := NewSigningKey("example-key", "EdDSA", publicKey, privateKey)
+	if err != nil {
+		panic(err)
+	}
+	if err = keyring.Add(signingKey); err != nil {
+		panic(err)
+	}
+	if err = keyring.Activate(signingKey.ID); err != nil {
+		panic(err)
+	}
+
 	jwtHandler := &PascalDeKloeJWTHandler{
-		algorithm:    "EdDSA",
-		publicKey:    publicKey,
-		privateKey:   privateKey,
+		keyring:      keyring,
 		timeProvider: timeProvider.Now,
 		idProvider:   idGenerator,
 		timeToLive:   time.Minute * 10,
@@ -58,7 +68,7 @@ func init() {
 
 	obscureTokenHandler := &ObscureUUIDTokenHandler{
 		idProvider:      idGenerator,
-		stringGenerator: func(length int) string { return "AAA" },
+		secretGenerator: func() (string, error) { return "AAA", nil },
 	}
 	inMemoryTokenRepo := NewInMemoryTokenPersistence()
 	tokenProvider = &JWTTokenProvider{
@@ -78,11 +88,14 @@ func init() {
 
 	localProvider, _ = NewLocalProvider(localAPI, localAccountSync)
 
+	googleProvider, _ := NewGoogleProvider([]string{"730840734736.apps.googleusercontent.com"})
+	facebookProvider, _ := NewFacebookProvider("2372425976349832", "app-secret")
+
 	// Those are the available providers.
 	providerFactory := NewProviderFactory(map[ProviderName]Provider{
-		Google:   GoogleProvider{googlePeople{}},
+		Google:   googleProvider,
 		Local:    localProvider,
-		Facebook: FacebookProvider{handuFacebook{}},
+		Facebook: facebookProvider,
 	})
 
 	// Retrieves the provider based on the constant.
@@ -128,7 +141,7 @@ func ExampleAuthenticationPoolProvider_Authenticate() {
 
 	// Output: the given user does not exist
 	// the given user needs to be validated
-	// eyJhbGciOiJFZERTQSJ9.eyJlbWFpbCI6ImFueUBnbWFpbC5jb20iLCJlbWFpbF92ZXJpZmllZCI6ZmFsc2UsImV4cCI6MTU1NDM0MjAwLCJmYW1pbHlfbmFtZSI6IiIsImdpdmVuX25hbWUiOiIiLCJpYXQiOjE1NTQzMzYwMCwiaXNzIjoiYXBwIiwianRpIjoiSkpKSjpJSUlJIiwibmFtZSI6IiAiLCJuYmYiOjE1NTQzMzYwMCwicGhvbmVfbnVtYmVyIjoiIiwicGhvbmVfbnVtYmVyX3ZlcmlmaWVkIjpmYWxzZSwicGljdHVyZSI6bnVsbCwic3ViIjoiSkpKSiJ9
+	// eyJhbGciOiJFZERTQSIsImtpZCI6ImV4YW1wbGUta2V5In0.eyJlbWFpbCI6ImFueUBnbWFpbC5jb20iLCJlbWFpbF92ZXJpZmllZCI6dHJ1ZSwiZXhwIjoxNTU0MzQyMDAsImZhbWlseV9uYW1lIjoiIiwiZ2l2ZW5fbmFtZSI6IiIsImlhdCI6MTU1NDMzNjAwLCJpc3MiOiJhcHAiLCJqdGkiOiJKSkpKOklJSUkiLCJuYW1lIjoiICIsIm5iZiI6MTU1NDMzNjAwLCJwaG9uZV9udW1iZXIiOiIiLCJwaG9uZV9udW1iZXJfdmVyaWZpZWQiOmZhbHNlLCJwaWN0dXJlIjpudWxsLCJzdWIiOiJKSkpKIn0
 	// SkpKSj1ISEhIOkFBQTpKSkpK
 }
 
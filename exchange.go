@@ -0,0 +1,164 @@
+package authentication_pool
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var _ TokenExchanger = &JWTTokenProvider{}
+
+// AccessTokenType is the token type identifier of the access tokens defined by RFC 8693.
+const AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+
+const defaultExchangeTimeToLive = time.Minute * 5
+
+// TokenExchanger issues tokens for the services that act on behalf of a user, as defined by RFC 8693.
+type TokenExchanger interface {
+	// Exchange takes a valid access token and issues a short lived one for the given actor. The new token can only
+	// narrow the audience and the scope of the subject token.
+	Exchange(input *TokenExchangeInput) (*TokenExchangeOutput, error)
+}
+
+type TokenExchangeInput struct {
+	SubjectToken string
+	// Audience must be a subset of the subject token audience, when it is empty the audience is kept.
+	Audience []string
+	// Scope must be a subset of the subject token scope, when it is empty the scope is kept.
+	Scope string
+	// Actor is the service that acts on behalf of the subject.
+	Actor string
+}
+
+type TokenExchangeOutput struct {
+	AccessToken     *Token
+	IssuedTokenType string
+}
+
+// TokenExchangeLifetime sets the life of the exchanged tokens, it is five minutes by default. The exchanged tokens never
+// outlive the subject token.
+func TokenExchangeLifetime(timeToLive time.Duration) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if timeToLive <= 0 {
+			return errors.New("the exchanged tokens need a time to live")
+		}
+
+		provider.exchangeTimeToLive = timeToLive
+		return nil
+	}
+}
+
+// Exchange checks the subject token status in the persistence even when the provider is not stateful. The exchanged
+// token is related to the subject token, so it is revoked along with its family. The exchanged token is bound to the
+// DPoP key of the subject token, a leaked token cannot be exchanged for a bearer one.
+func (j JWTTokenProvider) Exchange(input *TokenExchangeInput) (*TokenExchangeOutput, error) {
+	if input.Actor == "" {
+		return nil, errors.New("the actor is required")
+	}
+
+	result, entity, err := j.readAccessToken(input.SubjectToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = j.validateClaims(result, true); err != nil {
+		return nil, err
+	}
+
+	if entity == nil {
+		entity, err = j.persistence.Find(result.RegisteredClaims.JsonWebTokenID)
+		if err == ErrNotFound {
+			return nil, ErrInvalidToken
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if entity.Status != TokenEnabled {
+		return nil, ErrDisabledToken
+	}
+
+	audience := result.RegisteredClaims.Audience
+	if len(input.Audience) > 0 {
+		if !isSubset(input.Audience, audience) {
+			return nil, ErrInvalidAudience
+		}
+		audience = input.Audience
+	}
+
+	scope := result.RegisteredClaims.Scope
+	if input.Scope != "" {
+		if !isSubset(strings.Fields(input.Scope), strings.Fields(scope)) {
+			return nil, ErrInvalidScope
+		}
+		scope = input.Scope
+	}
+
+	timeToLive := j.exchangeTimeToLive
+	if timeToLive == 0 {
+		timeToLive = defaultExchangeTimeToLive
+	}
+	remaining := result.ExpiredAt.Sub(j.timeProvider())
+	if remaining <= 0 {
+		return nil, ErrExpiredToken
+	}
+	if remaining < timeToLive {
+		timeToLive = remaining
+	}
+
+	output, err := j.jwtHandler.Issue(&IssueInput{
+		RegisteredClaims: RegisteredClaims{
+			Issuer:   j.issuer,
+			Subject:  result.RegisteredClaims.Subject,
+			Audience: audience,
+			Scope:    scope,
+			ClientID: result.RegisteredClaims.ClientID,
+			Actor:    &Actor{Subject: input.Actor, Actor: result.RegisteredClaims.Actor},
+			// The confirmation is kept, so the exchanged token needs the same proofs as the subject token.
+			Confirmation: result.RegisteredClaims.Confirmation,
+		},
+		PublicClaims:  *result.PublicClaims,
+		PrivateClaims: *result.PrivateClaims,
+		TimeToLive:    timeToLive,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := j.timeProvider()
+	exchanged := NewEntity(
+		output.Token.ID,
+		output.Token.TokenType,
+		result.RegisteredClaims.Subject,
+		output.Token.Content,
+		&entity.ID,
+		&output.Token.ExpireAt)
+	exchanged.Scope = scope
+	exchanged.ClientID = result.RegisteredClaims.ClientID
+	exchanged.DPoPThumbprint = entity.DPoPThumbprint
+	exchanged.CreatedAt = now
+	exchanged.UpdatedAt = now
+
+	if err = j.persistence.Save(exchanged); err != nil {
+		return nil, err
+	}
+
+	return &TokenExchangeOutput{AccessToken: output.Token, IssuedTokenType: AccessTokenType}, nil
+}
+
+// isSubset tells if every value of the subset is in the set.
+func isSubset(subset, set []string) bool {
+	values := make(map[string]bool, len(set))
+	for _, value := range set {
+		values[value] = true
+	}
+
+	for _, value := range subset {
+		if !values[value] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,127 @@
+package authentication_pool
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJWTTokenProvider_Exchange(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt)
+	j.audience = []string{"app-ID", "orders"}
+
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer", Email: "customer@example.com", Scope: "orders:read orders:write"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	got, err := j.Exchange(&TokenExchangeInput{
+		SubjectToken: tokens.AccessToken.Content,
+		Audience:     []string{"orders"},
+		Scope:        "orders:read",
+		Actor:        "gateway",
+	})
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	if got.IssuedTokenType != AccessTokenType || !got.AccessToken.ExpireAt.Equal(issuedAt.Add(time.Minute*5)) {
+		t.Errorf("Exchange() got = %v, %v", got.IssuedTokenType, got.AccessToken.ExpireAt)
+	}
+
+	claims, err := j.jwtHandler.Verify(&VerifyInput{got.AccessToken.Content})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	want := &RegisteredClaims{
+		Issuer:         "app",
+		Subject:        "customer",
+		Audience:       []string{"orders"},
+		JsonWebTokenID: got.AccessToken.ID,
+		Scope:          "orders:read",
+		Actor:          &Actor{Subject: "gateway"},
+	}
+	if !reflect.DeepEqual(claims.RegisteredClaims, want) || claims.PublicClaims.Email != "customer@example.com" {
+		t.Errorf("Exchange() got claims = %v, want %v", claims.RegisteredClaims, want)
+	}
+
+	t.Run("nests the actors", func(t *testing.T) {
+		nested, err := j.Exchange(&TokenExchangeInput{SubjectToken: got.AccessToken.Content, Actor: "orders-service"})
+		if err != nil {
+			t.Fatalf("Exchange() error = %v", err)
+		}
+
+		claims, _ := j.jwtHandler.Verify(&VerifyInput{nested.AccessToken.Content})
+		want := &Actor{Subject: "orders-service", Actor: &Actor{Subject: "gateway"}}
+		if !reflect.DeepEqual(claims.RegisteredClaims.Actor, want) {
+			t.Errorf("Exchange() got actor = %v, want %v", claims.RegisteredClaims.Actor, want)
+		}
+	})
+
+	t.Run("cannot widen the token", func(t *testing.T) {
+		_, err := j.Exchange(&TokenExchangeInput{SubjectToken: got.AccessToken.Content, Scope: "orders:write", Actor: "gateway"})
+		if err != ErrInvalidScope {
+			t.Errorf("Exchange() error = %v, want %v", err, ErrInvalidScope)
+		}
+
+		_, err = j.Exchange(&TokenExchangeInput{SubjectToken: got.AccessToken.Content, Audience: []string{"app-ID"}, Actor: "gateway"})
+		if err != ErrInvalidAudience {
+			t.Errorf("Exchange() error = %v, want %v", err, ErrInvalidAudience)
+		}
+	})
+
+	t.Run("is revoked along with the subject token family", func(t *testing.T) {
+		refreshToken, _ := j.persistence.Find(tokens.RefreshToken.ID)
+		if err := j.revokeFamily(refreshToken); err != nil {
+			t.Fatalf("revokeFamily() error = %v", err)
+		}
+
+		if entity, _ := j.persistence.Find(got.AccessToken.ID); entity.Status != TokenRevoked {
+			t.Errorf("revokeFamily() exchanged token status = %v, want %v", entity.Status, TokenRevoked)
+		}
+
+		_, err := j.Exchange(&TokenExchangeInput{SubjectToken: tokens.AccessToken.Content, Actor: "gateway"})
+		if err != ErrDisabledToken {
+			t.Errorf("Exchange() error = %v, want %v", err, ErrDisabledToken)
+		}
+	})
+}
+
+func TestJWTTokenProvider_ExchangeDPoPBoundToken(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt)
+	j.dpopProofs = newDPoPReplayCache(time.Minute)
+	client := newTestDPoPClient()
+
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer", DPoPThumbprint: client.thumbprint()})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	got, err := j.Exchange(&TokenExchangeInput{SubjectToken: tokens.AccessToken.Content, Actor: "gateway"})
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	exchanged := got.AccessToken.Content
+
+	if _, err = j.Verify(exchanged); err != ErrDPoPProofRequired {
+		t.Errorf("Verify() of the exchanged token error = %v, want %v", err, ErrDPoPProofRequired)
+	}
+
+	input := &VerifyDPoPInput{
+		AccessToken: exchanged,
+		Proof:       newTestDPoPClient().proof("proof-1", "GET", "https://api.example.com/orders", exchanged, issuedAt),
+		Method:      "GET",
+		URL:         "https://api.example.com/orders",
+	}
+	if _, err = j.VerifyDPoP(input); err != ErrInvalidDPoPProof {
+		t.Errorf("VerifyDPoP() with another key error = %v, want %v", err, ErrInvalidDPoPProof)
+	}
+
+	input.Proof = client.proof("proof-2", "GET", "https://api.example.com/orders", exchanged, issuedAt)
+	if _, err = j.VerifyDPoP(input); err != nil {
+		t.Errorf("VerifyDPoP() error = %v", err)
+	}
+}
@@ -1,42 +1,133 @@
 package authentication_pool
 
 import (
+	"errors"
 	"github.com/huandu/facebook"
+	"time"
 )
 
+// DefaultFacebookGraphVersion is the Graph API version used when none is configured. The version is pinned so the
+// responses do not change when Facebook releases a new one.
+const DefaultFacebookGraphVersion = "v8.0"
+
 type FacebookProvider struct {
 	api facebookAPI
 }
 
-func NewFacebookProvider() *FacebookProvider {
-	return &FacebookProvider{api: &handuFacebook{}}
+type FacebookProviderOptions func(h *handuFacebook) error
+
+// FacebookGraphVersion sets the Graph API version, like "v8.0".
+func FacebookGraphVersion(version string) FacebookProviderOptions {
+	return func(h *handuFacebook) error {
+		if version == "" {
+			return errors.New("the Graph API version cannot be empty")
+		}
+
+		h.version = version
+		return nil
+	}
+}
+
+// NewFacebookProvider creates a provider that only accepts the access tokens issued to the given app, the tokens are
+// checked with the debug_token endpoint before reading the user.
+func NewFacebookProvider(appID, appSecret string, opts ...FacebookProviderOptions) (*FacebookProvider, error) {
+	if appID == "" || appSecret == "" {
+		return nil, errors.New("the app ID and the app secret are required")
+	}
+
+	api := newHanduFacebook(appID, appSecret)
+	for _, opt := range opts {
+		if err := opt(api); err != nil {
+			return nil, err
+		}
+	}
+
+	return &FacebookProvider{api: api}, nil
 }
 
 type facebookAPI interface {
 	GetUser(accessToken string) (*FacebookUser, error)
 }
 
-type handuFacebook struct{}
+type handuFacebook struct {
+	app          *facebook.App
+	version      string
+	baseURL      string
+	timeProvider timeProvider
+}
+
+func newHanduFacebook(appID, appSecret string) *handuFacebook {
+	return &handuFacebook{
+		app:          facebook.New(appID, appSecret),
+		version:      DefaultFacebookGraphVersion,
+		timeProvider: osTimeProvider,
+	}
+}
+
+// facebookTokenInfo is the result of the debug_token endpoint.
+type facebookTokenInfo struct {
+	AppID     string `facebook:"app_id"`
+	UserID    string `facebook:"user_id"`
+	IsValid   bool   `facebook:"is_valid"`
+	ExpiresAt int64  `facebook:"expires_at"`
+}
 
 func (h handuFacebook) GetUser(accessToken string) (user *FacebookUser, err error) {
-	user = &FacebookUser{}
+	if err = h.checkToken(accessToken); err != nil {
+		return
+	}
 
-	res, err := facebook.Get(
-		"/me?fields=id,picture{url},first_name,last_name,email",
-		facebook.Params{"access_token": accessToken},
-	)
+	// The calls made with the user token are signed with the app secret, so a stolen token cannot be used by another
+	// app through this server.
+	session := h.session(accessToken)
+	if err = session.EnableAppsecretProof(true); err != nil {
+		return
+	}
 
+	res, err := session.Get("/me", facebook.Params{"fields": "id,picture{url},first_name,last_name,email"})
 	if err != nil {
 		return
 	}
 
+	user = &FacebookUser{}
 	if err = res.Decode(user); err != nil {
-		return
+		return nil, err
 	}
 
 	return
 }
 
+// checkToken rejects the tokens issued to other apps, the invalid and the expired ones.
+func (h handuFacebook) checkToken(accessToken string) error {
+	res, err := h.session(accessToken).Inspect()
+	if err != nil {
+		return err
+	}
+
+	info := &facebookTokenInfo{}
+	if err = res.Decode(info); err != nil {
+		return err
+	}
+
+	if !info.IsValid || info.AppID != h.app.AppId || info.UserID == "" {
+		return NewValidationInputFailed("the given token is not valid")
+	}
+
+	// The long-lived tokens have no expiration.
+	if info.ExpiresAt != 0 && !h.timeProvider().Before(time.Unix(info.ExpiresAt, 0)) {
+		return NewValidationInputFailed("the given token has expired")
+	}
+
+	return nil
+}
+
+func (h handuFacebook) session(accessToken string) *facebook.Session {
+	session := h.app.Session(accessToken)
+	session.Version = h.version
+	session.BaseURL = h.baseURL
+	return session
+}
+
 type FacebookUser struct {
 	ID        string
 	FirstName string
@@ -1,8 +1,15 @@
 package authentication_pool
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_handuFacebook_GetUser(t *testing.T) {
@@ -36,7 +43,7 @@ func Test_handuFacebook_GetUser(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			h := handuFacebook{}
+			h := newHanduFacebook("2372425976349832", "app-secret")
 			got, err := h.GetUser(tt.args.accessToken)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetUser() error = %v, wantErr %v", err, tt.wantErr)
@@ -48,3 +55,98 @@ func Test_handuFacebook_GetUser(t *testing.T) {
 		})
 	}
 }
+
+func TestFacebookProvider_Retrieve(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	tokenInfo := map[string]interface{}{}
+	var proof string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v7.0/debug_token":
+			if r.URL.Query().Get("access_token") != "app-id|app-secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			respondJSON(w, http.StatusOK, map[string]interface{}{"data": tokenInfo})
+		case "/v7.0/me":
+			proof = r.URL.Query().Get("appsecret_proof")
+			respondJSON(w, http.StatusOK, map[string]string{"id": "143090040460812", "first_name": "Jane", "email": "jane@example.com"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f, err := NewFacebookProvider("app-id", "app-secret", FacebookGraphVersion("v7.0"))
+	if err != nil {
+		t.Fatalf("NewFacebookProvider() error = %v", err)
+	}
+	api := f.api.(*handuFacebook)
+	api.baseURL = server.URL + "/"
+	api.timeProvider = newFixedTimeProvider(now).Now
+
+	tests := []struct {
+		name      string
+		tokenInfo map[string]interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "token of the app",
+			tokenInfo: map[string]interface{}{"app_id": "app-id", "user_id": "143090040460812", "is_valid": true, "expires_at": now.Add(time.Hour).Unix()},
+		},
+		{
+			name:      "long-lived token",
+			tokenInfo: map[string]interface{}{"app_id": "app-id", "user_id": "143090040460812", "is_valid": true, "expires_at": 0},
+		},
+		{
+			name:      "token of another app",
+			tokenInfo: map[string]interface{}{"app_id": "another-app", "user_id": "143090040460812", "is_valid": true},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid token",
+			tokenInfo: map[string]interface{}{"app_id": "app-id", "user_id": "143090040460812", "is_valid": false},
+			wantErr:   true,
+		},
+		{
+			name:      "expired token",
+			tokenInfo: map[string]interface{}{"app_id": "app-id", "user_id": "143090040460812", "is_valid": true, "expires_at": now.Unix()},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenInfo, proof = tt.tokenInfo, ""
+
+			got, err := f.Retrieve(&ValidationInput{Secret: "user-token"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Retrieve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			if got.ID != "143090040460812" || got.Email != "jane@example.com" {
+				t.Errorf("Retrieve() got = %v", got)
+			}
+
+			mac := hmac.New(sha256.New, []byte("app-secret"))
+			mac.Write([]byte("user-token"))
+			if want := hex.EncodeToString(mac.Sum(nil)); proof != want {
+				t.Errorf("Retrieve() sent appsecret_proof = %v, want %v", proof, want)
+			}
+		})
+	}
+}
+
+// respondJSON writes the responses of the fake providers.
+func respondJSON(w http.ResponseWriter, status int, content interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(content); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,232 @@
+package authentication_pool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGitHubAPIURL is the API of github.com, GitHub Enterprise servers have their own.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// defaultGitHubHTTPClient gives up on the API when it does not answer, so a hung server does not block the logins.
+var defaultGitHubHTTPClient = &http.Client{Timeout: time.Second * 10}
+
+// GitHubProvider validates the OAuth access tokens of GitHub. The tokens need the user:email scope to read the
+// emails of the user.
+type GitHubProvider struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+}
+
+type GitHubProviderOptions func(p *GitHubProvider) error
+
+// GitHubAPIURL sets the URL of the API, like "https://github.example.com/api/v3" for GitHub Enterprise.
+func GitHubAPIURL(url string) GitHubProviderOptions {
+	return func(p *GitHubProvider) error {
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			return errors.New("the GitHub API URL must be an URL")
+		}
+
+		p.baseURL = strings.TrimSuffix(url, "/")
+		return nil
+	}
+}
+
+// GitHubHTTPClient sets the client used to call the API, the default client gives up after 10 seconds.
+func GitHubHTTPClient(client *http.Client) GitHubProviderOptions {
+	return func(p *GitHubProvider) error {
+		if client == nil {
+			return errors.New("the HTTP client cannot be nil")
+		}
+
+		p.client = client
+		return nil
+	}
+}
+
+// NewGitHubProvider creates a provider that only accepts the access tokens issued to the given OAuth app, the tokens
+// are checked with the credentials of the app before reading the user.
+func NewGitHubProvider(clientID, clientSecret string, opts ...GitHubProviderOptions) (*GitHubProvider, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, errors.New("the client ID and the client secret are required")
+	}
+
+	provider := &GitHubProvider{
+		baseURL:      DefaultGitHubAPIURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       defaultGitHubHTTPClient,
+	}
+	for _, opt := range opts {
+		if err := opt(provider); err != nil {
+			return nil, err
+		}
+	}
+
+	return provider, nil
+}
+
+type GitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// gitHubTokenInfo is the result of the check of a token.
+type gitHubTokenInfo struct {
+	App struct {
+		ClientID string `json:"client_id"`
+	} `json:"app"`
+}
+
+type GitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// Retrieve reads the user of the access token given as secret. Only the primary email is used and it must be verified,
+// otherwise anyone could take the accounts of other providers by adding their email to GitHub.
+func (g *GitHubProvider) Retrieve(input *ValidationInput) (*ValidationOutput, error) {
+	if err := g.checkToken(input.Secret); err != nil {
+		return nil, err
+	}
+
+	user := &GitHubUser{}
+	if err := g.get("/user", input.Secret, user); err != nil {
+		return nil, err
+	}
+
+	emails := make([]*GitHubEmail, 0)
+	if err := g.get("/user/emails", input.Secret, &emails); err != nil {
+		return nil, err
+	}
+
+	email := primaryVerifiedEmail(emails)
+	if email == "" {
+		return nil, NewValidationInputFailed("the GitHub account has no verified primary email")
+	}
+
+	// The name is optional in GitHub, the login is always there.
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+
+	output := NewValidationOutput(strconv.FormatInt(user.ID, 10), name, "", email, nil, true)
+	output.Username = user.Login
+	if user.AvatarURL != "" {
+		output.PhotoURL = &user.AvatarURL
+	}
+
+	return output, nil
+}
+
+func (g *GitHubProvider) Name() string {
+	return "github"
+}
+
+func primaryVerifiedEmail(emails []*GitHubEmail) string {
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email
+		}
+	}
+
+	return ""
+}
+
+// checkToken rejects the tokens issued to other apps, otherwise a token given to any app by the user could be used to
+// log in here.
+func (g *GitHubProvider) checkToken(accessToken string) error {
+	body, err := json.Marshal(map[string]string{"access_token": accessToken})
+	if err != nil {
+		return err
+	}
+
+	path := "/applications/" + url.PathEscape(g.clientID) + "/token"
+	request, err := http.NewRequest(http.MethodPost, g.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.SetBasicAuth(g.clientID, g.clientSecret)
+	request.Header.Set("Content-Type", "application/json")
+
+	info := &gitHubTokenInfo{}
+	err = g.do(request, info, func(status int) error {
+		switch status {
+		case http.StatusNotFound, http.StatusUnprocessableEntity:
+			// GitHub answers 404 to the tokens that do not belong to the app.
+			return NewValidationInputFailed("the given token is not valid")
+		case http.StatusUnauthorized:
+			err := errors.New("the GitHub API rejected the client credentials")
+			return NewProviderError(err, "invalid response from server. Please try again")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if info.App.ClientID != g.clientID {
+		return NewValidationInputFailed("the given token is not valid")
+	}
+
+	return nil
+}
+
+func (g *GitHubProvider) get(path, accessToken string, content interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, g.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "token "+accessToken)
+
+	return g.do(request, content, func(status int) error {
+		switch status {
+		case http.StatusUnauthorized:
+			return NewValidationInputFailed("the given token is not valid")
+		case http.StatusForbidden, http.StatusNotFound:
+			// The token does not have the scope of the resource.
+			return NewValidationInputFailed("the given token cannot read the user")
+		}
+
+		return nil
+	})
+}
+
+// do sends the request and decodes the response. The statuses other than 200 are given to statusError, the statuses
+// that it does not handle are provider errors.
+func (g *GitHubProvider) do(request *http.Request, content interface{}, statusError func(status int) error) error {
+	request.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	response, err := g.client.Do(request)
+	if err != nil {
+		return NewProviderError(err, "invalid response from server. Please try again")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		if err = statusError(response.StatusCode); err != nil {
+			return err
+		}
+
+		err = fmt.Errorf("the GitHub API returned the status %d", response.StatusCode)
+		return NewProviderError(err, "invalid response from server. Please try again")
+	}
+
+	return json.NewDecoder(response.Body).Decode(content)
+}
@@ -0,0 +1,125 @@
+package authentication_pool
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitHubProvider_Retrieve(t *testing.T) {
+	var emails []map[string]interface{}
+	name := "The Octocat"
+	// tokens are the apps the access tokens have been issued to.
+	tokens := map[string]string{"gho_valid": "client-1", "gho_another_app": "client-2"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/applications/client-1/token" {
+			body := map[string]string{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if clientID, secret, ok := r.BasicAuth(); !ok || clientID != "client-1" || secret != "client-secret" {
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+			} else if tokens[body["access_token"]] != "client-1" {
+				respondJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			} else {
+				respondJSON(w, http.StatusOK, map[string]interface{}{"app": map[string]string{"client_id": "client-1"}})
+			}
+			return
+		}
+
+		if r.Header.Get("Authorization") != "token gho_valid" && r.Header.Get("Authorization") != "token gho_another_app" {
+			respondJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/v3/user":
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"id": 583231, "login": "octocat", "name": name, "avatar_url": "https://example.com/octocat.png",
+			})
+		case "/api/v3/user/emails":
+			respondJSON(w, http.StatusOK, emails)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g, err := NewGitHubProvider("client-1", "client-secret", GitHubAPIURL(server.URL+"/api/v3/"))
+	if err != nil {
+		t.Fatalf("NewGitHubProvider() error = %v", err)
+	}
+
+	t.Run("primary verified email", func(t *testing.T) {
+		emails = []map[string]interface{}{
+			{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		}
+
+		got, err := g.Retrieve(&ValidationInput{Secret: "gho_valid"})
+		if err != nil {
+			t.Fatalf("Retrieve() error = %v", err)
+		}
+
+		if got.ID != "583231" || got.Username != "octocat" || got.FirstName != "The Octocat" || got.Email != "octocat@example.com" ||
+			!got.EmailValidated || got.PhotoURL == nil || *got.PhotoURL != "https://example.com/octocat.png" {
+			t.Errorf("Retrieve() got = %+v", got)
+		}
+	})
+
+	t.Run("without name", func(t *testing.T) {
+		name = ""
+		defer func() { name = "The Octocat" }()
+
+		got, err := g.Retrieve(&ValidationInput{Secret: "gho_valid"})
+		if err != nil {
+			t.Fatalf("Retrieve() error = %v", err)
+		}
+
+		if got.FirstName != "octocat" {
+			t.Errorf("Retrieve() FirstName = %v, want octocat", got.FirstName)
+		}
+	})
+
+	t.Run("primary email not verified", func(t *testing.T) {
+		emails = []map[string]interface{}{
+			{"email": "octocat@example.com", "primary": true, "verified": false},
+			{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+		}
+
+		if _, err := g.Retrieve(&ValidationInput{Secret: "gho_valid"}); err == nil {
+			t.Errorf("Retrieve() error = nil")
+		}
+	})
+
+	t.Run("token of another app", func(t *testing.T) {
+		_, err := g.Retrieve(&ValidationInput{Secret: "gho_another_app"})
+		if _, ok := err.(*ValidationInputFailed); !ok {
+			t.Errorf("Retrieve() error = %v, want a validation error", err)
+		}
+	})
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		g, _ := NewGitHubProvider("client-1", "another-secret", GitHubAPIURL(server.URL+"/api/v3/"))
+		_, err := g.Retrieve(&ValidationInput{Secret: "gho_valid"})
+		if _, ok := err.(*ProviderError); !ok {
+			t.Errorf("Retrieve() error = %v, want a provider error", err)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := g.Retrieve(&ValidationInput{Secret: "gho_invalid"})
+		if _, ok := err.(*ValidationInputFailed); !ok {
+			t.Errorf("Retrieve() error = %v, want a validation error", err)
+		}
+	})
+}
+
+func TestNewGitHubProvider(t *testing.T) {
+	if _, err := NewGitHubProvider("", "client-secret"); err == nil {
+		t.Errorf("NewGitHubProvider() without client ID error = nil")
+	}
+
+	if _, err := NewGitHubProvider("client-1", ""); err == nil {
+		t.Errorf("NewGitHubProvider() without client secret error = nil")
+	}
+}
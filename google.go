@@ -2,17 +2,47 @@ package authentication_pool
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 )
 
 type GoogleProvider struct {
-	api googleAPI
+	api          googleAPI
+	clientIDs    []string
+	hostedDomain string
 }
 
-func NewGoogleProvider() *GoogleProvider {
-	return &GoogleProvider{api: &googlePeople{}}
+type GoogleProviderOptions func(p *GoogleProvider) error
+
+// GoogleHostedDomain only accepts the accounts of the given G Suite domain.
+func GoogleHostedDomain(domain string) GoogleProviderOptions {
+	return func(p *GoogleProvider) error {
+		if domain == "" {
+			return errors.New("the hosted domain cannot be empty")
+		}
+
+		p.hostedDomain = domain
+		return nil
+	}
+}
+
+// NewGoogleProvider creates a provider that only accepts the id_tokens issued to the given client IDs, so the tokens
+// issued to other applications cannot be used to log in.
+func NewGoogleProvider(clientIDs []string, opts ...GoogleProviderOptions) (*GoogleProvider, error) {
+	if len(clientIDs) == 0 {
+		return nil, errors.New("at least one client ID is required")
+	}
+
+	provider := &GoogleProvider{api: &googlePeople{}, clientIDs: clientIDs}
+	for _, opt := range opts {
+		if err := opt(provider); err != nil {
+			return nil, err
+		}
+	}
+
+	return provider, nil
 }
 
 type googleAPI interface {
@@ -52,11 +82,36 @@ func (h googlePeople) GetUser(accessToken string) (user *GoogleUser, err error)
 }
 
 type GoogleUser struct {
-	ID        string `json:"sub"`
-	FirstName string `json:"given_name"`
-	LastName  string `json:"family_name"`
-	Email     string `json:"email"`
-	Picture   string `json:"picture"`
+	ID            string     `json:"sub"`
+	Issuer        string     `json:"iss"`
+	Audience      string     `json:"aud"`
+	HostedDomain  string     `json:"hd"`
+	FirstName     string     `json:"given_name"`
+	LastName      string     `json:"family_name"`
+	Email         string     `json:"email"`
+	EmailVerified googleBool `json:"email_verified"`
+	Picture       string     `json:"picture"`
+}
+
+// googleBool reads the booleans of the tokeninfo endpoint, they are sent as strings.
+type googleBool bool
+
+func (b *googleBool) UnmarshalJSON(content []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*b = googleBool(v)
+	case string:
+		*b = v == "true"
+	default:
+		*b = false
+	}
+
+	return nil
 }
 
 func (f GoogleProvider) Retrieve(input *ValidationInput) (*ValidationOutput, error) {
@@ -65,16 +120,47 @@ func (f GoogleProvider) Retrieve(input *ValidationInput) (*ValidationOutput, err
 		return nil, err
 	}
 
+	if err = f.validateUser(user); err != nil {
+		return nil, err
+	}
+
 	return &ValidationOutput{
 		ID:             user.ID,
 		FirstName:      user.FirstName,
 		LastName:       user.LastName,
 		Email:          user.Email,
 		PhotoURL:       &user.Picture,
-		EmailValidated: true,
+		EmailValidated: bool(user.EmailVerified),
 	}, nil
 }
 
+// validateUser checks that the token was issued by Google to one of our clients.
+func (f GoogleProvider) validateUser(user *GoogleUser) error {
+	if user.Issuer != "accounts.google.com" && user.Issuer != "https://accounts.google.com" {
+		return NewValidationInputFailed("the given token is not valid")
+	}
+
+	if !f.acceptAudience(user.Audience) {
+		return NewValidationInputFailed("the given token has been issued for another application")
+	}
+
+	if f.hostedDomain != "" && user.HostedDomain != f.hostedDomain {
+		return NewValidationInputFailed("the given account does not belong to the allowed domain")
+	}
+
+	return nil
+}
+
+func (f GoogleProvider) acceptAudience(audience string) bool {
+	for _, clientID := range f.clientIDs {
+		if clientID == audience {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (f GoogleProvider) Name() string {
 	return "google"
 }
@@ -1,6 +1,7 @@
 package authentication_pool
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 )
@@ -38,3 +39,73 @@ func Test_googlePeople_GetUser(t *testing.T) {
 		})
 	}
 }
+
+type fakeGoogleAPI struct {
+	user *GoogleUser
+}
+
+func (f fakeGoogleAPI) GetUser(accessToken string) (*GoogleUser, error) {
+	return f.user, nil
+}
+
+func TestGoogleProvider_Retrieve(t *testing.T) {
+	newUser := func(change func(user *GoogleUser)) *GoogleUser {
+		user := &GoogleUser{
+			ID:            "101148353795937669386",
+			Issuer:        "https://accounts.google.com",
+			Audience:      "our-client",
+			HostedDomain:  "example.com",
+			FirstName:     "Jane",
+			LastName:      "Doe",
+			Email:         "jane@example.com",
+			EmailVerified: true,
+		}
+		change(user)
+		return user
+	}
+
+	tests := []struct {
+		name              string
+		user              *GoogleUser
+		wantEmailVerified bool
+		wantErr           bool
+	}{
+		{name: "valid token", user: newUser(func(user *GoogleUser) {}), wantEmailVerified: true},
+		{name: "not verified email", user: newUser(func(user *GoogleUser) { user.EmailVerified = false })},
+		{name: "another application", user: newUser(func(user *GoogleUser) { user.Audience = "their-client" }), wantErr: true},
+		{name: "another domain", user: newUser(func(user *GoogleUser) { user.HostedDomain = "gmail.com" }), wantErr: true},
+		{name: "another issuer", user: newUser(func(user *GoogleUser) { user.Issuer = "https://example.com" }), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewGoogleProvider([]string{"our-client"}, GoogleHostedDomain("example.com"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.api = fakeGoogleAPI{tt.user}
+
+			got, err := f.Retrieve(&ValidationInput{Secret: "id-token"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Retrieve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.EmailValidated != tt.wantEmailVerified {
+				t.Errorf("Retrieve() got EmailValidated = %v, want %v", got.EmailValidated, tt.wantEmailVerified)
+			}
+		})
+	}
+}
+
+func TestGoogleUser_UnmarshalJSON(t *testing.T) {
+	for content, want := range map[string]bool{
+		`{"email_verified":"true"}`:  true,
+		`{"email_verified":true}`:    true,
+		`{"email_verified":"false"}`: false,
+		`{}`:                         false,
+	} {
+		user := &GoogleUser{}
+		if err := json.Unmarshal([]byte(content), user); err != nil || bool(user.EmailVerified) != want {
+			t.Errorf("Unmarshal(%s) got = %v, error = %v, want %v", content, user.EmailVerified, err, want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package authentication_pool
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type InMemoryLocalAPI struct {
 	emailSet    map[string]*LocalUser
@@ -81,6 +84,7 @@ type CustomerEntity struct {
 
 type InMemoryCustomerRepository struct {
 	set         map[string]*CustomerEntity
+	idSet       map[string]*CustomerEntity
 	idGenerator IDGenerator
 }
 
@@ -88,11 +92,13 @@ func NewInMemoryCustomerRepository(generator IDGenerator) *InMemoryCustomerRepos
 	return &InMemoryCustomerRepository{
 		idGenerator: generator,
 		set:         map[string]*CustomerEntity{},
+		idSet:       map[string]*CustomerEntity{},
 	}
 }
 
 func (i *InMemoryCustomerRepository) Clear() {
 	i.set = map[string]*CustomerEntity{}
+	i.idSet = map[string]*CustomerEntity{}
 }
 
 func (i InMemoryCustomerRepository) Create(input *CreateLocalAccountInput) (*LocalAccount, error) {
@@ -110,6 +116,7 @@ func (i InMemoryCustomerRepository) Create(input *CreateLocalAccountInput) (*Loc
 	}
 
 	i.set[input.Email] = entity
+	i.idSet[entity.ID] = entity
 	return modelToEntity(entity), nil
 }
 
@@ -121,9 +128,18 @@ func (i InMemoryCustomerRepository) Find(input *FindLocalAccountInput) (*LocalAc
 	}
 }
 
+func (i InMemoryCustomerRepository) FindByID(input *FindLocalAccountByIDInput) (*LocalAccount, error) {
+	if user, ok := i.idSet[input.ID]; !ok {
+		return nil, nil
+	} else {
+		return modelToEntity(user), nil
+	}
+}
+
 func (i InMemoryCustomerRepository) Delete(input *DeleteLocalAccountInput) (*LocalAccount, error) {
-	if v, ok := i.set[input.Email]; !ok {
+	if v, ok := i.set[input.Email]; ok {
 		delete(i.set, input.Email)
+		delete(i.idSet, v.ID)
 		return modelToEntity(v), nil
 	}
 
@@ -226,27 +242,136 @@ func (i InMemoryFederatedAccountRepository) Find(input *FindFederatedAccountInpu
 	}
 }
 
+// InMemoryTokenPersistence keeps copies of the entities, the entities returned can be changed by the callers and the
+// changes are only stored through Update.
 type InMemoryTokenPersistence struct {
 	set map[string]*Entity
+	mx  sync.RWMutex
 }
 
 func NewInMemoryTokenPersistence() *InMemoryTokenPersistence {
 	return &InMemoryTokenPersistence{set: map[string]*Entity{}}
 }
 
-func (i InMemoryTokenPersistence) Save(entity *Entity) error {
+func (i *InMemoryTokenPersistence) Save(entity *Entity) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+
 	if _, ok := i.set[entity.ID]; ok {
 		return ErrDuplicatedEntityExists
 	}
 
-	i.set[entity.ID] = entity
+	i.set[entity.ID] = copyEntity(entity)
 	return nil
 }
 
-func (i InMemoryTokenPersistence) Find(tokenID string) (*Entity, error) {
+func (i *InMemoryTokenPersistence) Find(tokenID string) (*Entity, error) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+
 	if t, ok := i.set[tokenID]; ok {
-		return t, nil
+		return copyEntity(t), nil
 	}
 
 	return nil, ErrNotFound
 }
+
+func (i *InMemoryTokenPersistence) Update(entity *Entity) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+
+	if _, ok := i.set[entity.ID]; !ok {
+		return ErrNotFound
+	}
+
+	i.set[entity.ID] = copyEntity(entity)
+	return nil
+}
+
+func (i *InMemoryTokenPersistence) UpdateStatus(tokenID, from, to string, updatedAt time.Time) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+
+	entity, ok := i.set[tokenID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if entity.Status != from {
+		return ErrStatusChanged
+	}
+
+	entity.Status = to
+	entity.UpdatedAt = updatedAt
+	return nil
+}
+
+func (i *InMemoryTokenPersistence) FindRelated(tokenID string) ([]*Entity, error) {
+	return i.filter(func(t *Entity) bool { return t.RelatedTokenID != nil && *t.RelatedTokenID == tokenID }), nil
+}
+
+func (i *InMemoryTokenPersistence) FindByUser(userID string) ([]*Entity, error) {
+	return i.filter(func(t *Entity) bool { return t.UserID == userID }), nil
+}
+
+func (i *InMemoryTokenPersistence) FindSessions(userID string) ([]*Entity, error) {
+	return i.filter(func(t *Entity) bool {
+		return t.UserID == userID && t.Type == RefreshTokenType && t.Status == TokenEnabled
+	}), nil
+}
+
+func (i *InMemoryTokenPersistence) ListPurgeable(before time.Time) ([]*Entity, error) {
+	return i.filter(func(t *Entity) bool { return IsPurgeable(t, before) }), nil
+}
+
+func (i *InMemoryTokenPersistence) Delete(tokenID string) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+
+	if _, ok := i.set[tokenID]; !ok {
+		return ErrNotFound
+	}
+
+	delete(i.set, tokenID)
+	return nil
+}
+
+func (i *InMemoryTokenPersistence) filter(match func(t *Entity) bool) []*Entity {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+
+	result := make([]*Entity, 0)
+	for _, t := range i.set {
+		if match(t) {
+			result = append(result, copyEntity(t))
+		}
+	}
+
+	return result
+}
+
+// copyEntity returns a copy of the entity that does not share memory with it.
+func copyEntity(entity *Entity) *Entity {
+	result := *entity
+	if entity.RelatedTokenID != nil {
+		relatedTokenID := *entity.RelatedTokenID
+		result.RelatedTokenID = &relatedTokenID
+	}
+
+	if entity.ExpiredAt != nil {
+		expiredAt := *entity.ExpiredAt
+		result.ExpiredAt = &expiredAt
+	}
+
+	if entity.SessionExpiredAt != nil {
+		sessionExpiredAt := *entity.SessionExpiredAt
+		result.SessionExpiredAt = &sessionExpiredAt
+	}
+
+	if entity.Session != nil {
+		session := *entity.Session
+		result.Session = &session
+	}
+
+	return &result
+}
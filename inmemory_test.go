@@ -0,0 +1,57 @@
+package authentication_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInMemoryTokenPersistence_Update(t *testing.T) {
+	expiredAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	persistence := NewInMemoryTokenPersistence()
+	if err := persistence.Save(&Entity{ID: "token", Status: TokenEnabled, ExpiredAt: &expiredAt, Session: &Session{ID: "session"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, _ := persistence.Find("token")
+	found.Status = TokenRevoked
+	found.Session.IPAddress = "127.0.0.1"
+	*found.ExpiredAt = expiredAt.Add(time.Hour)
+
+	stored, _ := persistence.Find("token")
+	if stored.Status != TokenEnabled || stored.Session.IPAddress != "" || !stored.ExpiredAt.Equal(expiredAt) {
+		t.Errorf("Find() got a shared entity = %+v", stored)
+	}
+
+	if err := persistence.Update(found); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if stored, _ = persistence.Find("token"); stored.Status != TokenRevoked || stored.Session.IPAddress != "127.0.0.1" {
+		t.Errorf("Update() got = %+v", stored)
+	}
+}
+
+func TestInMemoryCustomerRepository_Delete(t *testing.T) {
+	repository := NewInMemoryCustomerRepository(UUIDGenerator)
+	created, err := repository.Create(&CreateLocalAccountInput{Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := repository.Delete(&DeleteLocalAccountInput{Email: "jane@example.com"})
+	if err != nil || deleted.ID != created.ID {
+		t.Fatalf("Delete() got = %v, error = %v", deleted, err)
+	}
+
+	if found, _ := repository.Find(&FindLocalAccountInput{Email: "jane@example.com"}); found != nil {
+		t.Errorf("Find() got = %v, want nil", found)
+	}
+
+	if found, _ := repository.FindByID(&FindLocalAccountByIDInput{ID: created.ID}); found != nil {
+		t.Errorf("FindByID() got = %v, want nil", found)
+	}
+
+	if _, err = repository.Delete(&DeleteLocalAccountInput{Email: "jane@example.com"}); err != ErrNotFound {
+		t.Errorf("Delete() of a missing account error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,179 @@
+package authentication_pool
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// TokenIntrospector returns the state of a token as defined by RFC 7662.
+type TokenIntrospector interface {
+	// Introspect takes an access token, JWT or opaque, or a refresh token and returns its claims. The invalid, expired
+	// and revoked tokens are not active, in that case the output only has the Active property and there is no error.
+	Introspect(input *IntrospectTokenInput) (*IntrospectTokenOutput, error)
+}
+
+type IntrospectTokenInput struct {
+	Token string
+	// TokenTypeHint is "access_token" or "refresh_token", the token type is found out from the token anyway.
+	TokenTypeHint string
+}
+
+type IntrospectTokenOutput struct {
+	Active         bool     `json:"active"`
+	Subject        string   `json:"sub,omitempty"`
+	ExpiredAt      int64    `json:"exp,omitempty"`
+	IssuedAt       int64    `json:"iat,omitempty"`
+	Scope          string   `json:"scope,omitempty"`
+	ClientID       string   `json:"client_id,omitempty"`
+	TokenType      string   `json:"token_type,omitempty"`
+	Issuer         string   `json:"iss,omitempty"`
+	Audience       []string `json:"aud,omitempty"`
+	JsonWebTokenID string   `json:"jti,omitempty"`
+	// Confirmation is the key of the DPoP tokens, the resource servers must check the proof of the key.
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+}
+
+var _ TokenIntrospector = &JWTTokenProvider{}
+
+// Introspect reads the token and checks its status in the persistence.
+func (j JWTTokenProvider) Introspect(input *IntrospectTokenInput) (*IntrospectTokenOutput, error) {
+	result, entity, err := j.introspect(input.Token)
+	if isInactiveTokenError(err) {
+		return &IntrospectTokenOutput{Active: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	output := &IntrospectTokenOutput{
+		Active:         true,
+		Subject:        result.RegisteredClaims.Subject,
+		ExpiredAt:      unixTime(result.ExpiredAt),
+		IssuedAt:       unixTime(result.IssuedAt),
+		Scope:          result.RegisteredClaims.Scope,
+		ClientID:       result.RegisteredClaims.ClientID,
+		TokenType:      entity.Type,
+		Issuer:         result.RegisteredClaims.Issuer,
+		Audience:       result.RegisteredClaims.Audience,
+		JsonWebTokenID: result.RegisteredClaims.JsonWebTokenID,
+	}
+
+	if entity.Type == RefreshTokenType {
+		output.TokenType = "refresh_token"
+	}
+
+	if entity.DPoPThumbprint != "" {
+		output.TokenType = "DPoP"
+		output.Confirmation = &Confirmation{JWKThumbprint: entity.DPoPThumbprint}
+	}
+
+	return output, nil
+}
+
+func (j JWTTokenProvider) introspect(token string) (*VerifyOutput, *Entity, error) {
+	var (
+		result *VerifyOutput
+		entity *Entity
+		err    error
+	)
+
+	if isSignedToken(token) {
+		if result, err = j.jwtHandler.Verify(&VerifyInput{token}); err != nil {
+			return nil, nil, err
+		}
+
+		if entity, err = j.persistence.Find(result.RegisteredClaims.JsonWebTokenID); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if entity, err = j.findObscureToken(token); err != nil {
+			return nil, nil, err
+		}
+
+		result = j.opaqueClaims(entity)
+	}
+
+	if entity.Status != TokenEnabled {
+		return nil, nil, ErrDisabledToken
+	}
+
+	// The refresh tokens may not have expiration, the validation of the expiration is skipped for them.
+	checkExpiration := entity.Type != RefreshTokenType || entity.ExpiredAt != nil
+	if err = j.validateClaims(result, checkExpiration); err != nil {
+		return nil, nil, err
+	}
+
+	return result, entity, nil
+}
+
+// isInactiveTokenError tells if the error was caused by the token, those tokens are not active. The other errors come
+// from the infrastructure.
+func isInactiveTokenError(err error) bool {
+	switch err {
+	case ErrInvalidToken, ErrExpiredToken, ErrDisabledToken, ErrNotFound, ErrInvalidIssuer, ErrInvalidAudience,
+		ErrTokenNotValidYet, ErrTokenIssuedInFuture:
+		return true
+	default:
+		return false
+	}
+}
+
+func unixTime(input time.Time) int64 {
+	if input.IsZero() {
+		return 0
+	}
+
+	return input.Unix()
+}
+
+// ClientAuthenticator tells if the request comes from a client allowed to call the endpoint.
+type ClientAuthenticator func(r *http.Request) bool
+
+// IntrospectionHandler serves the token introspection endpoint defined by RFC 7662.
+type IntrospectionHandler struct {
+	introspector  TokenIntrospector
+	authenticator ClientAuthenticator
+}
+
+// NewIntrospectionHandler creates the handler. The endpoint must be protected, the requests are rejected unless the
+// authenticator accepts them.
+func NewIntrospectionHandler(introspector TokenIntrospector, authenticator ClientAuthenticator) *IntrospectionHandler {
+	return &IntrospectionHandler{introspector: introspector, authenticator: authenticator}
+}
+
+func (i *IntrospectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if i.authenticator == nil || !i.authenticator(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	output, err := i.introspector.Introspect(&IntrospectTokenInput{
+		Token:         r.PostForm.Get("token"),
+		TokenTypeHint: r.PostForm.Get("token_type_hint"),
+	})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, output)
+}
+
+func writeJSON(w http.ResponseWriter, status int, content interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(content)
+}
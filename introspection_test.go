@@ -0,0 +1,135 @@
+package authentication_pool
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWTTokenProvider_Introspect(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	jwtProvider := newTestJWTTokenProvider(now, now)
+	opaqueProvider := newTestJWTTokenProvider(now, now)
+	opaqueProvider.opaqueTimeToLive = time.Minute
+
+	for name, j := range map[string]*JWTTokenProvider{"jwt": jwtProvider, "opaque": opaqueProvider} {
+		t.Run(name, func(t *testing.T) {
+			tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer", Scope: "orders:read", ClientID: "partner"})
+			if err != nil {
+				t.Fatalf("CreateToken() error = %v", err)
+			}
+
+			if _, err = j.Verify(tokens.AccessToken.Content); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+
+			got, err := j.Introspect(&IntrospectTokenInput{Token: tokens.AccessToken.Content})
+			if err != nil {
+				t.Fatalf("Introspect() error = %v", err)
+			}
+
+			want := &IntrospectTokenOutput{
+				Active:    true,
+				Subject:   "customer",
+				Scope:     "orders:read",
+				ClientID:  "partner",
+				ExpiredAt: tokens.AccessToken.ExpireAt.Unix(),
+			}
+			if got.Active != want.Active || got.Subject != want.Subject || got.Scope != want.Scope ||
+				got.ClientID != want.ClientID || got.ExpiredAt != want.ExpiredAt {
+				t.Errorf("Introspect() got = %v, want %v", got, want)
+			}
+
+			bound, err := j.CreateToken(&CreateTokenInput{ID: "customer", DPoPThumbprint: "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I"})
+			if err != nil {
+				t.Fatalf("CreateToken() error = %v", err)
+			}
+
+			got, err = j.Introspect(&IntrospectTokenInput{Token: bound.AccessToken.Content})
+			if err != nil || got.TokenType != "DPoP" || got.Confirmation == nil ||
+				got.Confirmation.JWKThumbprint != "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I" {
+				t.Errorf("Introspect() DPoP token got = %v, %v", got, err)
+			}
+
+			refresh, err := j.Introspect(&IntrospectTokenInput{Token: tokens.RefreshToken.Token})
+			if err != nil || !refresh.Active || refresh.TokenType != "refresh_token" {
+				t.Errorf("Introspect() refresh token got = %v, %v", refresh, err)
+			}
+
+			if err = j.Revoke(tokens.AccessToken.ID); err != nil {
+				t.Fatalf("Revoke() error = %v", err)
+			}
+
+			got, err = j.Introspect(&IntrospectTokenInput{Token: tokens.AccessToken.Content})
+			if err != nil || got.Active {
+				t.Errorf("Introspect() revoked token got = %v, %v", got, err)
+			}
+		})
+	}
+
+	t.Run("an opaque refresh token is not an access token", func(t *testing.T) {
+		tokens, err := opaqueProvider.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		if _, err = opaqueProvider.Verify(tokens.RefreshToken.Token); err != ErrInvalidToken {
+			t.Errorf("Verify() error = %v, want %v", err, ErrInvalidToken)
+		}
+	})
+
+	t.Run("an unknown token is not active", func(t *testing.T) {
+		got, err := opaqueProvider.Introspect(&IntrospectTokenInput{Token: "unknown"})
+		if err != nil || got.Active {
+			t.Errorf("Introspect() got = %v, %v", got, err)
+		}
+	})
+}
+
+func TestIntrospectionHandler_ServeHTTP(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	j := newTestJWTTokenProvider(now, now)
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	handler := NewIntrospectionHandler(j, func(r *http.Request) bool {
+		user, password, ok := r.BasicAuth()
+		return ok && user == "gateway" && password == "secret"
+	})
+
+	request := func(token string, authenticated bool) *httptest.ResponseRecorder {
+		body := url.Values{"token": {token}}.Encode()
+		r := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if authenticated {
+			r.SetBasicAuth("gateway", "secret")
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	recorder := request(tokens.AccessToken.Content, true)
+	output := &IntrospectTokenOutput{}
+	if err = json.Unmarshal(recorder.Body.Bytes(), output); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || !output.Active || output.Subject != "customer" {
+		t.Errorf("ServeHTTP() status = %v, got = %v", recorder.Code, output)
+	}
+
+	if recorder = request(tokens.AccessToken.Content, false); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("ServeHTTP() status = %v, want %v", recorder.Code, http.StatusUnauthorized)
+	}
+
+	if recorder = request("", true); recorder.Code != http.StatusBadRequest {
+		t.Errorf("ServeHTTP() status = %v, want %v", recorder.Code, http.StatusBadRequest)
+	}
+}
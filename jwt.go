@@ -1,7 +1,13 @@
 package authentication_pool
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
@@ -19,17 +25,182 @@ type JWTTokenProvider struct {
 	obscureHandler ObscureTokenHandler
 	timeProvider   timeProvider
 	persistence    TokenPersistence
+	statefulVerify bool
+	leeway         time.Duration
+	// opaqueTimeToLive is the life of the opaque access tokens, when it is zero the access tokens are JWT.
+	opaqueTimeToLive time.Duration
+	// exchangeTimeToLive is the life of the exchanged tokens, when it is zero defaultExchangeTimeToLive is used.
+	exchangeTimeToLive time.Duration
+	// dpopProofs remembers the DPoP proofs already used, it is nil when DPoP is not enabled.
+	dpopProofs     *dpopReplayCache
+	claimsEnricher ClaimsEnricher
+	// idleTimeout and absoluteTimeout limit the life of the refresh tokens, zero means no limit.
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+	// refreshWindow is the fraction of the access token life, at its end, during which it can be refreshed.
+	refreshWindow    float64
+	refreshTokenOnly bool
+	tokenHasher      TokenHasher
+	sessionLimit     *SessionLimitPolicy
+	// idProvider generates the session IDs, UUIDGenerator is used when it is nil.
+	idProvider IDGenerator
+	// authorizationEndpoint and responseTypes are published in the discovery document, the provider does not serve
+	// the authorization requests itself.
+	authorizationEndpoint string
+	responseTypes         []string
 }
 
-func NewJWTTokenProvider(issuer string, audience []string, jwtHandler JWTHandler, obscureHandler ObscureTokenHandler, persistence TokenPersistence) *JWTTokenProvider {
-	return &JWTTokenProvider{
+// ClaimsEnricher returns the custom claims of the token issued to the given account, like roles, tenant IDs or
+// feature flags. It is called every time a token is issued, so the claims are up to date after a refresh.
+type ClaimsEnricher func(input *CreateTokenInput) (map[string]interface{}, error)
+
+type JWTTokenProviderOptions func(provider *JWTTokenProvider) error
+
+// StatefulVerify makes Verify read the token status from the persistence, so revoked tokens are rejected before they
+// expire.
+func StatefulVerify() JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		provider.statefulVerify = true
+		return nil
+	}
+}
+
+// ClockSkewLeeway accepts tokens whose time claims are off by the given duration, it covers the clock differences
+// between the servers.
+func ClockSkewLeeway(leeway time.Duration) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if leeway < 0 {
+			return errors.New("the leeway cannot be negative")
+		}
+
+		provider.leeway = leeway
+		return nil
+	}
+}
+
+// OpaqueAccessTokens issues random access tokens instead of JWT. The opaque tokens are verified against the
+// persistence, they do not carry claims so the clients must use the introspection to read them.
+func OpaqueAccessTokens(timeToLive time.Duration) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if timeToLive <= 0 {
+			return errors.New("the opaque tokens need a time to live")
+		}
+
+		provider.opaqueTimeToLive = timeToLive
+		return nil
+	}
+}
+
+// EnrichClaims adds the claims returned by the enricher to the issued tokens. The opaque access tokens do not carry
+// claims, the enricher is not called for them.
+func EnrichClaims(enricher ClaimsEnricher) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if enricher == nil {
+			return errors.New("the claims enricher cannot be nil")
+		}
+
+		provider.claimsEnricher = enricher
+		return nil
+	}
+}
+
+// SessionLifetime makes the refresh tokens expire. A refresh token expires when it is not used during the idle time,
+// every refresh extends the session but never beyond the absolute time counted from the authentication. Any of them
+// can be zero to disable it.
+func SessionLifetime(idle, absolute time.Duration) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if idle < 0 || absolute < 0 {
+			return errors.New("the session lifetime cannot be negative")
+		}
+
+		provider.idleTimeout = idle
+		provider.absoluteTimeout = absolute
+		return nil
+	}
+}
+
+// RefreshWindow allows to refresh the access token before it expires, during the given fraction of its life. With a
+// window of 0.2 a token that lives ten minutes can be refreshed during its last two minutes.
+func RefreshWindow(ratio float64) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if ratio <= 0 || ratio > 1 {
+			return errors.New("the refresh window must be greater than 0 and not greater than 1")
+		}
+
+		provider.refreshWindow = ratio
+		return nil
+	}
+}
+
+// AllowRefreshTokenOnly allows to refresh without the access token. The claims of the new access token are read from
+// the access token issued along with the refresh token.
+func AllowRefreshTokenOnly() JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		provider.refreshTokenOnly = true
+		return nil
+	}
+}
+
+// HashTokenSecrets saves the secrets of the refresh tokens and the opaque access tokens hashed. The tokens saved before
+// are accepted and hashed again the first time they are used, the same happens with the values made with an old key.
+func HashTokenSecrets(hasher TokenHasher) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if hasher == nil {
+			return errors.New("the token hasher cannot be nil")
+		}
+
+		provider.tokenHasher = hasher
+		return nil
+	}
+}
+
+// SessionLimit caps the number of sessions that a user can hold, see SessionLimitPolicy.
+func SessionLimit(policy SessionLimitPolicy) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if policy.MaxSessions < 0 {
+			return errors.New("the maximum number of sessions cannot be negative")
+		}
+		for _, max := range policy.MaxSessionsPerDeviceClass {
+			if max < 0 {
+				return errors.New("the maximum number of sessions cannot be negative")
+			}
+		}
+
+		provider.sessionLimit = &policy
+		return nil
+	}
+}
+
+// SessionIDGenerator sets the generator of the session IDs, UUIDGenerator is used by default.
+func SessionIDGenerator(generator IDGenerator) JWTTokenProviderOptions {
+	return func(provider *JWTTokenProvider) error {
+		if generator == nil {
+			return errors.New("the session ID generator cannot be nil")
+		}
+
+		provider.idProvider = generator
+		return nil
+	}
+}
+
+func NewJWTTokenProvider(issuer string, audience []string, jwtHandler JWTHandler, obscureHandler ObscureTokenHandler, persistence TokenPersistence, opts ...JWTTokenProviderOptions) (*JWTTokenProvider, error) {
+	provider := &JWTTokenProvider{
 		issuer:         issuer,
 		audience:       audience,
 		jwtHandler:     jwtHandler,
 		obscureHandler: obscureHandler,
 		persistence:    persistence,
 		timeProvider:   osTimeProvider,
+		idProvider:     UUIDGenerator,
 	}
+
+	for _, opt := range opts {
+		if err := opt(provider); err != nil {
+			return nil, err
+		}
+	}
+
+	return provider, nil
 }
 
 func (j JWTTokenProvider) CreateToken(input *CreateTokenInput) (*CreateTokenOutput, error) {
@@ -38,6 +209,8 @@ func (j JWTTokenProvider) CreateToken(input *CreateTokenInput) (*CreateTokenOutp
 			Issuer:   j.issuer,
 			Subject:  input.ID,
 			Audience: j.audience,
+			Scope:    input.Scope,
+			ClientID: input.ClientID,
 		},
 		PublicClaims: PublicClaims{
 			Name:                 input.Name,
@@ -53,48 +226,180 @@ func (j JWTTokenProvider) CreateToken(input *CreateTokenInput) (*CreateTokenOutp
 		PrivateClaims: PrivateClaims{},
 	}
 
-	output, err := j.jwtHandler.Issue(issueInput)
+	if input.DPoPThumbprint != "" {
+		issueInput.RegisteredClaims.Confirmation = &Confirmation{JWKThumbprint: input.DPoPThumbprint}
+	}
+
+	if j.sessionLimit != nil && !j.sessionLimit.EvictOldest {
+		if err := j.enforceSessionLimit(input, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	properties, err := j.enrichClaims(input)
 	if err != nil {
 		return nil, err
 	}
+	issueInput.PrivateClaims.Properties = properties
 
-	obscure, err := j.obscureHandler.Issue(input.ID)
+	accessToken, err := j.issueAccessToken(issueInput)
 	if err != nil {
 		return nil, err
 	}
 
-	tokens := &CreateTokenOutput{
-		AccessToken: output.Token,
-		RefreshToken: &RefreshToken{
-			ID:      obscure.ObscureToken.ID(),
-			Content: obscure.ObscureToken.Value(),
-			Token:   obscure.ObscureToken.Token(),
-		},
+	refreshToken, err := j.issueRefreshToken(input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:          j.sessionID(),
+		UserAgent:   input.UserAgent,
+		IPAddress:   input.IPAddress,
+		Provider:    input.Provider,
+		DeviceClass: input.DeviceClass,
+	}
+
+	err = j.persistTokens(accessToken, refreshToken, input.ID, nil, session)
+	if err != nil {
+		return nil, err
+	}
+
+	// The oldest sessions are evicted once the new one has been persisted, a failed login does not close them.
+	if j.sessionLimit != nil && j.sessionLimit.EvictOldest {
+		if err = j.enforceSessionLimit(input, refreshToken.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &CreateTokenOutput{
+		AccessToken:  accessToken.token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (j JWTTokenProvider) enrichClaims(input *CreateTokenInput) (map[string]interface{}, error) {
+	if j.claimsEnricher == nil || j.opaqueTimeToLive != 0 {
+		return nil, nil
+	}
+
+	return j.claimsEnricher(input)
+}
+
+// issuedAccessToken is an access token along with the values saved in the persistence.
+type issuedAccessToken struct {
+	token *Token
+	// secret is the content saved in the persistence. For the opaque tokens is the random part of the token.
+	secret string
+	opaque bool
+	claims *RegisteredClaims
+}
+
+func (j JWTTokenProvider) issueAccessToken(input *IssueInput) (*issuedAccessToken, error) {
+	if j.opaqueTimeToLive == 0 {
+		output, err := j.jwtHandler.Issue(input)
+		if err != nil {
+			return nil, err
+		}
+
+		return &issuedAccessToken{token: output.Token, secret: output.Token.Content, claims: &input.RegisteredClaims}, nil
+	}
+
+	obscure, err := j.obscureHandler.Issue(input.RegisteredClaims.Subject)
+	if err != nil {
+		return nil, err
 	}
 
-	err = j.persistTokens(tokens, input.ID)
+	input.RegisteredClaims.JsonWebTokenID = obscure.ObscureToken.ID()
+	return &issuedAccessToken{
+		token: &Token{
+			ID:         obscure.ObscureToken.ID(),
+			TokenType:  "Bearer",
+			Content:    obscure.ObscureToken.Token(),
+			ExpireAt:   j.timeProvider().Add(j.opaqueTimeToLive),
+			TimeToLive: int64(j.opaqueTimeToLive),
+		},
+		secret: obscure.ObscureToken.Value(),
+		opaque: true,
+		claims: &input.RegisteredClaims,
+	}, nil
+}
+
+func (j JWTTokenProvider) issueRefreshToken(accountID string) (*RefreshToken, error) {
+	obscure, err := j.obscureHandler.Issue(accountID)
 	if err != nil {
 		return nil, err
 	}
 
-	return tokens, nil
+	return &RefreshToken{
+		ID:      obscure.ObscureToken.ID(),
+		Content: obscure.ObscureToken.Value(),
+		Token:   obscure.ObscureToken.Token(),
+	}, nil
 }
 
-func (j JWTTokenProvider) persistTokens(tokens *CreateTokenOutput, accountID string) (err error) {
+// persistTokens saves the access and the refresh token. The parent is the refresh token that was exchanged to get the
+// given tokens, it links the access token with the previous ones, so the whole family can be revoked. The session is
+// saved with the refresh token, its dates are set here.
+func (j JWTTokenProvider) persistTokens(access *issuedAccessToken, refresh *RefreshToken, accountID string, parent *Entity, session *Session) (err error) {
+	now := j.timeProvider()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.LastUsedAt = now
+
+	var (
+		parentID         *string
+		sessionExpiredAt *time.Time
+	)
+	if parent != nil {
+		parentID = &parent.ID
+		sessionExpiredAt = parent.SessionExpiredAt
+	} else if j.absoluteTimeout != 0 {
+		limit := now.Add(j.absoluteTimeout)
+		sessionExpiredAt = &limit
+	}
+
+	accessSecret := access.secret
+	if access.opaque {
+		if accessSecret, err = j.hashSecret(access.secret); err != nil {
+			return
+		}
+	}
+
+	refreshSecret, err := j.hashSecret(refresh.Content)
+	if err != nil {
+		return
+	}
+
 	accessToken := NewEntity(
-		tokens.AccessToken.ID,
-		tokens.AccessToken.TokenType,
+		access.token.ID,
+		access.token.TokenType,
 		accountID,
-		tokens.AccessToken.Content,
-		nil,
-		&tokens.AccessToken.ExpireAt)
+		accessSecret,
+		parentID,
+		&access.token.ExpireAt)
+	accessToken.Scope = access.claims.Scope
+	accessToken.ClientID = access.claims.ClientID
+	if access.claims.Confirmation != nil {
+		accessToken.DPoPThumbprint = access.claims.Confirmation.JWKThumbprint
+	}
+	accessToken.CreatedAt = now
+	accessToken.UpdatedAt = now
+
 	refreshToken := NewEntity(
-		tokens.RefreshToken.ID,
-		"refresh",
+		refresh.ID,
+		RefreshTokenType,
 		accountID,
-		tokens.RefreshToken.Content,
-		&tokens.AccessToken.ID,
-		nil)
+		refreshSecret,
+		&access.token.ID,
+		j.refreshTokenExpiration(now, sessionExpiredAt))
+	refreshToken.SessionExpiredAt = sessionExpiredAt
+	refreshToken.Session = session
+	refreshToken.Scope = access.claims.Scope
+	refreshToken.ClientID = access.claims.ClientID
+	refreshToken.CreatedAt = now
+	refreshToken.UpdatedAt = now
 
 	if err = j.persistence.Save(accessToken); err != nil {
 		return
@@ -103,84 +408,603 @@ func (j JWTTokenProvider) persistTokens(tokens *CreateTokenOutput, accountID str
 		return
 	}
 
+	refresh.ExpireAt = refreshToken.ExpiredAt
 	return
 }
 
+// refreshTokenExpiration returns the end of the idle time, it cannot go beyond the end of the session.
+func (j JWTTokenProvider) refreshTokenExpiration(now time.Time, sessionExpiredAt *time.Time) *time.Time {
+	if j.idleTimeout == 0 {
+		return sessionExpiredAt
+	}
+
+	expiredAt := now.Add(j.idleTimeout)
+	if sessionExpiredAt != nil && sessionExpiredAt.Before(expiredAt) {
+		expiredAt = *sessionExpiredAt
+	}
+
+	return &expiredAt
+}
+
+// Verify rejects the tokens bound to a DPoP key, those must be verified with VerifyDPoP.
 func (j JWTTokenProvider) Verify(input string) (*VerifyTokenOutput, error) {
-	result, err := j.jwtHandler.Verify(&VerifyInput{input})
+	result, err := j.verify(input)
 	if err != nil {
 		return nil, err
 	}
 
-	if !j.validTime(result.ExpiredAt) {
-		return nil, ErrExpiredToken
+	if result.RegisteredClaims.Confirmation != nil {
+		return nil, ErrDPoPProofRequired
 	}
 
-	return &VerifyTokenOutput{Valid: true, CustomerEmail: &result.PublicClaims.Email}, nil
+	return newVerifyTokenOutput(result), nil
 }
 
-func (j JWTTokenProvider) validTime(input time.Time) bool {
-	now := j.timeProvider()
-
-	return input.After(now)
+func newVerifyTokenOutput(result *VerifyOutput) *VerifyTokenOutput {
+	return &VerifyTokenOutput{
+		Valid:         true,
+		Subject:       result.RegisteredClaims.Subject,
+		CustomerEmail: &result.PublicClaims.Email,
+		Claims:        result,
+	}
 }
 
-func (j JWTTokenProvider) Refresh(input *RefreshTokenInput) (*RefreshTokenOutput, error) {
-	result, err := j.jwtHandler.Verify(&VerifyInput{input.AccessToken})
+// verify reads the access token and validates its claims and its status.
+func (j JWTTokenProvider) verify(input string) (*VerifyOutput, error) {
+	result, entity, err := j.readAccessToken(input)
 	if err != nil {
 		return nil, err
 	}
 
-	if j.validTime(result.ExpiredAt) {
-		return nil, errors.New("the given access token has not expired")
+	if err = j.validateClaims(result, true); err != nil {
+		return nil, err
+	}
+
+	if entity == nil && j.statefulVerify {
+		entity, err = j.persistence.Find(result.RegisteredClaims.JsonWebTokenID)
+		if err == ErrNotFound {
+			return nil, ErrInvalidToken
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if entity != nil && entity.Status != TokenEnabled {
+		return nil, ErrDisabledToken
+	}
+
+	return result, nil
+}
+
+// readAccessToken checks the signature of a JWT or the secret of an opaque token and returns its claims, the claims
+// are not validated. The opaque tokens are read from the persistence, so the stored entity is returned too.
+func (j JWTTokenProvider) readAccessToken(token string) (*VerifyOutput, *Entity, error) {
+	if isSignedToken(token) {
+		result, err := j.jwtHandler.Verify(&VerifyInput{token})
+		return result, nil, err
+	}
+
+	entity, err := j.findObscureToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if entity.Type == RefreshTokenType {
+		return nil, nil, ErrInvalidToken
+	}
+
+	return j.opaqueClaims(entity), entity, nil
+}
+
+// findObscureToken retrieves the entity of an opaque access token or a refresh token and checks its secret.
+func (j JWTTokenProvider) findObscureToken(token string) (*Entity, error) {
+	obscureToken, err := NewObscureTokenFromRawContent(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	entity, err := j.persistence.Find(obscureToken.ID())
+	if err == ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	obscureToken, err := NewObscureTokenFromRawContent(input.RefreshToken)
+	valid, err := j.compareSecret(obscureToken.Value(), entity.Content)
 	if err != nil {
 		return nil, err
 	}
+	if !valid {
+		return nil, ErrInvalidToken
+	}
+
+	if j.tokenHasher != nil && j.tokenHasher.NeedsRehash(entity.Content) {
+		if entity.Content, err = j.tokenHasher.Make(obscureToken.Value()); err != nil {
+			return nil, err
+		}
+
+		entity.UpdatedAt = j.timeProvider()
+		if err = j.persistence.Update(entity); err != nil {
+			return nil, err
+		}
+	}
+
+	return entity, nil
+}
+
+// hashSecret returns the value saved in the persistence for the given secret.
+func (j JWTTokenProvider) hashSecret(secret string) (string, error) {
+	if j.tokenHasher == nil {
+		return secret, nil
+	}
+
+	return j.tokenHasher.Make(secret)
+}
+
+func (j JWTTokenProvider) compareSecret(secret, stored string) (bool, error) {
+	if j.tokenHasher == nil {
+		return subtle.ConstantTimeCompare([]byte(secret), []byte(stored)) == 1, nil
+	}
+
+	return j.tokenHasher.Compare(secret, stored)
+}
+
+// opaqueClaims returns the claims of an opaque token, those are the values saved along with the token.
+func (j JWTTokenProvider) opaqueClaims(entity *Entity) *VerifyOutput {
+	var expiredAt time.Time
+	if entity.ExpiredAt != nil {
+		expiredAt = *entity.ExpiredAt
+	}
+
+	var confirmation *Confirmation
+	if entity.DPoPThumbprint != "" {
+		confirmation = &Confirmation{JWKThumbprint: entity.DPoPThumbprint}
+	}
+
+	return &VerifyOutput{
+		ExpiredAt: expiredAt,
+		IssuedAt:  entity.CreatedAt,
+		RegisteredClaims: &RegisteredClaims{
+			Issuer:         j.issuer,
+			Subject:        entity.UserID,
+			Audience:       j.audience,
+			JsonWebTokenID: entity.ID,
+			Scope:          entity.Scope,
+			ClientID:       entity.ClientID,
+			Confirmation:   confirmation,
+		},
+		PublicClaims:  &PublicClaims{},
+		PrivateClaims: &PrivateClaims{},
+	}
+}
+
+// isSignedToken tells if the token carries its claims, JWT or PASETO, instead of being an opaque token.
+func isSignedToken(token string) bool {
+	return strings.HasPrefix(token, pasetoV4PublicHeader) || strings.Count(token, ".") == 2
+}
+
+func (j JWTTokenProvider) Revoke(tokenID string) error {
+	entity, err := j.persistence.Find(tokenID)
+	if err != nil {
+		return err
+	}
+
+	return j.revokeEntity(entity)
+}
+
+func (j JWTTokenProvider) RevokeAllForUser(userID string) error {
+	entities, err := j.persistence.FindByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, entity := range entities {
+		if err = j.revokeEntity(entity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Logout revokes every token issued from the same authentication as the given access token: the refresh tokens of the
+// session and the tokens exchanged from them. The access token does not need to be alive, but its signature must be
+// valid.
+func (j JWTTokenProvider) Logout(accessToken string) error {
+	result, _, err := j.readAccessToken(accessToken)
+	if err != nil {
+		return err
+	}
+
+	if err = j.validateClaims(result, false); err != nil {
+		return err
+	}
+
+	entity, err := j.persistence.Find(result.RegisteredClaims.JsonWebTokenID)
+	if err != nil {
+		return err
+	}
+
+	return j.revokeFamily(entity)
+}
+
+func (j JWTTokenProvider) revokeEntity(entity *Entity) error {
+	if entity.Status == TokenRevoked {
+		return nil
+	}
+
+	entity.Status = TokenRevoked
+	entity.UpdatedAt = j.timeProvider()
+	return j.persistence.Update(entity)
+}
+
+// validateClaims checks that the token has been issued by this provider for one of its audiences and that it can be
+// used now. The expiration is optional because the expired tokens are used to refresh the session.
+func (j JWTTokenProvider) validateClaims(result *VerifyOutput, checkExpiration bool) error {
+	now := j.timeProvider()
+
+	if result.RegisteredClaims.Issuer != j.issuer {
+		return ErrInvalidIssuer
+	}
+
+	if !j.acceptAudience(result.RegisteredClaims.Audience) {
+		return ErrInvalidAudience
+	}
+
+	if !result.NotBefore.IsZero() && now.Add(j.leeway).Before(result.NotBefore) {
+		return ErrTokenNotValidYet
+	}
+
+	if !result.IssuedAt.IsZero() && now.Add(j.leeway).Before(result.IssuedAt) {
+		return ErrTokenIssuedInFuture
+	}
+
+	if checkExpiration && !now.Add(-j.leeway).Before(result.ExpiredAt) {
+		return ErrExpiredToken
+	}
+
+	return nil
+}
+
+// acceptAudience checks that the token has been issued for at least one of the provider audiences. A provider without
+// audience only accepts tokens without audience.
+func (j JWTTokenProvider) acceptAudience(audience []string) bool {
+	if len(j.audience) == 0 {
+		return len(audience) == 0
+	}
+
+	for _, expected := range j.audience {
+		for _, given := range audience {
+			if expected == given {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (j JWTTokenProvider) validTime(input time.Time) bool {
+	now := j.timeProvider()
+
+	return input.After(now)
+}
+
+func (j JWTTokenProvider) Refresh(input *RefreshTokenInput) (*RefreshTokenOutput, error) {
+	var (
+		result *VerifyOutput
+		err    error
+	)
+
+	if input.AccessToken != "" || !j.refreshTokenOnly {
+		if result, _, err = j.readAccessToken(input.AccessToken); err != nil {
+			return nil, err
+		}
+
+		if err = j.validateClaims(result, false); err != nil {
+			return nil, err
+		}
+
+		if !j.refreshable(result) {
+			return nil, ErrTokenNotExpired
+		}
+	}
 
-	refreshToken, err := j.persistence.Find(obscureToken.ID())
+	refreshToken, err := j.findObscureToken(input.RefreshToken)
 	if err != nil {
 		return nil, err
 	}
 
-	if refreshToken.Status != "enabled" {
+	if refreshToken.Type != RefreshTokenType {
+		return nil, ErrInvalidToken
+	}
+
+	if refreshToken.Status == TokenRotated {
+		return nil, j.revokeReusedFamily(refreshToken)
+	}
+
+	if refreshToken.Status != TokenEnabled {
 		return nil, ErrDisabledToken
 	}
 
-	if refreshToken.Content != obscureToken.Value() {
+	if refreshToken.ExpiredAt != nil && !j.validTime(*refreshToken.ExpiredAt) {
+		return nil, ErrExpiredToken
+	}
+
+	if result == nil {
+		if result, err = j.pairedAccessTokenClaims(refreshToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if refreshToken.UserID != result.RegisteredClaims.Subject {
 		return nil, ErrInvalidToken
 	}
 
-	issueTokenOutput, err := j.jwtHandler.Issue(&IssueInput{
+	// The custom claims are computed again, so the changes in the account are applied to the new token. Without
+	// enricher the claims of the previous token are kept.
+	privateClaims := *result.PrivateClaims
+	if j.claimsEnricher != nil {
+		if privateClaims.Properties, err = j.enrichClaims(createTokenInput(result)); err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, err := j.issueAccessToken(&IssueInput{
 		RegisteredClaims: *result.RegisteredClaims,
 		PublicClaims:     *result.PublicClaims,
-		PrivateClaims:    PrivateClaims{},
+		PrivateClaims:    privateClaims,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &RefreshTokenOutput{AccessToken: issueTokenOutput.Token}, nil
+	newRefreshToken, err := j.issueRefreshToken(refreshToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The previous refresh token is retired before saving the new one, a failure in between forces the user to log in
+	// again instead of leaving two valid refresh tokens. Only one of the concurrent refreshes of a token can retire it,
+	// the others are handled as a reuse.
+	err = j.persistence.UpdateStatus(refreshToken.ID, TokenEnabled, TokenRotated, j.timeProvider())
+	if err == ErrStatusChanged {
+		return nil, j.revokeReusedFamily(refreshToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = j.persistTokens(accessToken, newRefreshToken, refreshToken.UserID, refreshToken, j.nextSession(refreshToken)); err != nil {
+		return nil, err
+	}
+
+	// A reuse of the previous refresh token may have walked the family before the new tokens were saved, then the
+	// previous token is not rotated anymore and the new tokens are revoked here.
+	previous, err := j.persistence.Find(refreshToken.ID)
+	if err != nil {
+		return nil, err
+	}
+	if previous.Status != TokenRotated {
+		return nil, j.revokeReusedFamily(previous)
+	}
+
+	return &RefreshTokenOutput{AccessToken: accessToken.token, RefreshToken: newRefreshToken}, nil
+}
+
+// refreshable tells if the access token has expired or if it is in the refresh window.
+func (j JWTTokenProvider) refreshable(result *VerifyOutput) bool {
+	if !j.validTime(result.ExpiredAt) {
+		return true
+	}
+
+	if j.refreshWindow == 0 || result.IssuedAt.IsZero() {
+		return false
+	}
+
+	lifetime := result.ExpiredAt.Sub(result.IssuedAt)
+	remaining := result.ExpiredAt.Sub(j.timeProvider())
+	return float64(remaining) <= float64(lifetime)*j.refreshWindow
+}
+
+// pairedAccessTokenClaims returns the claims of the access token issued along with the given refresh token.
+func (j JWTTokenProvider) pairedAccessTokenClaims(refreshToken *Entity) (*VerifyOutput, error) {
+	if refreshToken.RelatedTokenID == nil {
+		return nil, ErrInvalidToken
+	}
+
+	entity, err := j.persistence.Find(*refreshToken.RelatedTokenID)
+	if err == ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := j.opaqueClaims(entity)
+	if isSignedToken(entity.Content) {
+		if result, err = j.jwtHandler.Verify(&VerifyInput{entity.Content}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = j.validateClaims(result, false); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// nextSession returns a copy of the session of the given refresh token. The refresh tokens issued before the sessions
+// existed get a new one.
+func (j JWTTokenProvider) nextSession(refreshToken *Entity) *Session {
+	if refreshToken.Session == nil {
+		return &Session{ID: j.sessionID(), CreatedAt: refreshToken.CreatedAt}
+	}
+
+	session := *refreshToken.Session
+	return &session
+}
+
+func (j JWTTokenProvider) sessionID() string {
+	if j.idProvider == nil {
+		return UUIDGenerator()
+	}
+
+	return j.idProvider()
+}
+
+// createTokenInput returns the account that the given claims were issued to.
+func createTokenInput(result *VerifyOutput) *CreateTokenInput {
+	return &CreateTokenInput{
+		ID:            result.RegisteredClaims.Subject,
+		Name:          result.PublicClaims.Name,
+		GivenName:     result.PublicClaims.GivenName,
+		FamilyName:    result.PublicClaims.FamilyName,
+		Email:         result.PublicClaims.Email,
+		EmailVerified: result.PublicClaims.EmailVerified,
+		Picture:       result.PublicClaims.Picture,
+		Scope:         result.RegisteredClaims.Scope,
+		ClientID:      result.RegisteredClaims.ClientID,
+	}
+}
+
+// revokeReusedFamily handles the reuse of the given refresh token, it returns ErrReusedToken once the family has been
+// revoked. The reused token is revoked before walking the family, so a concurrent refresh that saved its tokens after
+// the walk finds it revoked.
+func (j JWTTokenProvider) revokeReusedFamily(refreshToken *Entity) error {
+	current, err := j.persistence.Find(refreshToken.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = j.revokeEntity(current); err != nil {
+		return err
+	}
+
+	if err = j.revokeFamily(current); err != nil {
+		return err
+	}
+
+	return ErrReusedToken
+}
+
+// revokeFamily revokes every token issued from the same authentication.
+func (j JWTTokenProvider) revokeFamily(entity *Entity) error {
+	family, err := tokenFamily(j.persistence, entity)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range family {
+		if err = j.revokeEntity(member); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tokenFamily returns every token issued from the same authentication as the given one. It walks the RelatedTokenID
+// links up to the first issued token and then collects it and all the tokens that descend from it.
+func tokenFamily(persistence TokenPersistence, entity *Entity) ([]*Entity, error) {
+	root := entity
+	for root.RelatedTokenID != nil {
+		parent, err := persistence.Find(*root.RelatedTokenID)
+		if err == ErrNotFound {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		root = parent
+	}
+
+	family := make([]*Entity, 0)
+	visited := map[string]bool{}
+	pending := []*Entity{root}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		if visited[current.ID] {
+			continue
+		}
+		visited[current.ID] = true
+		family = append(family, current)
+
+		children, err := persistence.FindRelated(current.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		pending = append(pending, children...)
+	}
+
+	return family, nil
 }
 
 type PascalDeKloeJWTHandler struct {
-	algorithm     string
-	publicKey     []byte
-	privateKey    []byte
+	keyring       *Keyring
 	timeProvider  timeProvider
 	idProvider    IDGenerator
 	timeToLive    time.Duration
 	timeToBeValid time.Duration
 }
 
-func NewPascalDeKloeJWTHandler(algorithm string, publicKey, privateKey []byte, timeToLive time.Duration, timeToBeValid time.Duration) *PascalDeKloeJWTHandler {
+// NewPascalDeKloeJWTHandler creates a handler with a single signing key, its key ID is derived from the public key.
+// The EdDSA keys are the raw Ed25519 keys, the HMAC algorithms use the private key as the secret and ignore the public
+// key, the RSA and ECDSA keys are PEM encoded and the public key is derived from the private one when it is given.
+// Without private key the handler only verifies tokens, Issue returns ErrNoActiveKey.
+func NewPascalDeKloeJWTHandler(algorithm string, publicKey, privateKey []byte, timeToLive time.Duration, timeToBeValid time.Duration) (*PascalDeKloeJWTHandler, error) {
+	var (
+		key *SigningKey
+		err error
+	)
+
+	_, isHMAC := jwt.HMACAlgs[algorithm]
+	switch {
+	case algorithm == jwt.EdDSA:
+		var signer crypto.PrivateKey
+		if len(privateKey) != 0 {
+			signer = ed25519.PrivateKey(privateKey)
+		}
+		key, err = NewSigningKey("", algorithm, ed25519.PublicKey(publicKey), signer)
+	case isHMAC:
+		key, err = NewSigningKey(KeyIDFromSecret(privateKey), algorithm, nil, privateKey)
+	case len(privateKey) == 0:
+		key, err = NewSigningKeyFromPEM("", algorithm, publicKey)
+	default:
+		key, err = NewSigningKeyFromPEM("", algorithm, privateKey)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := NewKeyring(0)
+	if err = keyring.Add(key); err != nil {
+		return nil, err
+	}
+	// The public keys are kept as verify only, they cannot sign tokens.
+	if key.PrivateKey != nil {
+		if err = keyring.Activate(key.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewPascalDeKloeJWTHandlerWithKeyring(keyring, timeToLive, timeToBeValid), nil
+}
+
+// NewPascalDeKloeJWTHandlerWithKeyring creates a handler that signs with the active key of the keyring and verifies
+// with the key referenced by the "kid" header of the token.
+func NewPascalDeKloeJWTHandlerWithKeyring(keyring *Keyring, timeToLive time.Duration, timeToBeValid time.Duration) *PascalDeKloeJWTHandler {
 	return &PascalDeKloeJWTHandler{
-		algorithm:     algorithm,
-		publicKey:     publicKey,
-		privateKey:    privateKey,
+		keyring:       keyring,
 		timeProvider:  osTimeProvider,
 		idProvider:    UUIDGenerator,
 		timeToLive:    timeToLive,
@@ -212,10 +1036,20 @@ func UUIDGenerator() string {
 
 func (p PascalDeKloeJWTHandler) Issue(input *IssueInput) (*IssueOutput, error) {
 	now := p.timeProvider()
-	expireAt := now.Add(p.timeToLive)
+	timeToLive := p.timeToLive
+	if input.TimeToLive != 0 {
+		timeToLive = input.TimeToLive
+	}
+	expireAt := now.Add(timeToLive)
 	input.RegisteredClaims.JsonWebTokenID = fmt.Sprintf("%s:%s", input.RegisteredClaims.Subject, p.idProvider())
 
+	key, err := p.keyring.Active()
+	if err != nil {
+		return nil, err
+	}
+
 	c := jwt.Claims{
+		KeyID: key.ID,
 		Registered: jwt.Registered{
 			ID:        input.RegisteredClaims.JsonWebTokenID,
 			Issuer:    input.RegisteredClaims.Issuer,
@@ -225,19 +1059,13 @@ func (p PascalDeKloeJWTHandler) Issue(input *IssueInput) (*IssueOutput, error) {
 			Expires:   jwt.NewNumericTime(expireAt),
 			NotBefore: jwt.NewNumericTime(now.Add(p.timeToBeValid)),
 		},
-		Set: map[string]interface{}{
-			"email":                 input.PublicClaims.Email,
-			"name":                  input.PublicClaims.Name,
-			"family_name":           input.PublicClaims.FamilyName,
-			"email_verified":        input.PublicClaims.EmailVerified,
-			"given_name":            input.PublicClaims.GivenName,
-			"phone_number":          input.PublicClaims.PhoneNumber,
-			"phone_number_verified": input.PublicClaims.PhoneNumberVerified,
-			"picture":               input.PublicClaims.Picture,
-		},
 	}
 
-	token, err := c.EdDSASign(p.privateKey)
+	if c.Set, err = claimsSet(input); err != nil {
+		return nil, err
+	}
+
+	token, err := sign(&c, key)
 	if err != nil {
 		return nil, err
 	}
@@ -248,46 +1076,227 @@ func (p PascalDeKloeJWTHandler) Issue(input *IssueInput) (*IssueOutput, error) {
 			TokenType:  "Bearer",
 			Content:    string(token),
 			ExpireAt:   expireAt,
-			TimeToLive: int64(p.timeToLive),
+			TimeToLive: int64(timeToLive),
 		},
 		CreatedAt: now,
 	}, nil
 }
 
 func (p PascalDeKloeJWTHandler) Verify(input *VerifyInput) (*VerifyOutput, error) {
-	var photo *string
-	claims, err := jwt.EdDSACheck([]byte(input.Token), p.publicKey)
+	key, err := p.verificationKey(input.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := check([]byte(input.Token), key)
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
-	if v, ok := claims.Set["photo"]; ok {
+	return verifyOutput(&RegisteredClaims{
+		Issuer:         claims.Issuer,
+		Subject:        claims.Subject,
+		Audience:       claims.Audiences,
+		JsonWebTokenID: claims.ID,
+	}, claims.Expires.Time(), claims.NotBefore.Time(), claims.Issued.Time(), claims.Set), nil
+}
+
+// claimsSet returns the claims of the input that are not time or identity claims, they are encoded in the same way by
+// every token format.
+func claimsSet(input *IssueInput) (map[string]interface{}, error) {
+	set := map[string]interface{}{
+		"email":                 input.PublicClaims.Email,
+		"name":                  input.PublicClaims.Name,
+		"family_name":           input.PublicClaims.FamilyName,
+		"email_verified":        input.PublicClaims.EmailVerified,
+		"given_name":            input.PublicClaims.GivenName,
+		"phone_number":          input.PublicClaims.PhoneNumber,
+		"phone_number_verified": input.PublicClaims.PhoneNumberVerified,
+		"picture":               input.PublicClaims.Picture,
+	}
+
+	if input.RegisteredClaims.Scope != "" {
+		set["scope"] = input.RegisteredClaims.Scope
+	}
+	if input.RegisteredClaims.ClientID != "" {
+		set["client_id"] = input.RegisteredClaims.ClientID
+	}
+	if input.RegisteredClaims.Actor != nil {
+		set["act"] = actorClaim(input.RegisteredClaims.Actor)
+	}
+	if input.RegisteredClaims.Confirmation != nil {
+		set["cnf"] = map[string]interface{}{"jkt": input.RegisteredClaims.Confirmation.JWKThumbprint}
+	}
+
+	for _, properties := range []map[string]interface{}{input.PublicClaims.AdditionalProperties, input.PrivateClaims.Properties} {
+		for name, value := range properties {
+			if reservedClaims[name] {
+				return nil, fmt.Errorf("the claim %q is reserved", name)
+			}
+
+			set[name] = value
+		}
+	}
+
+	return set, nil
+}
+
+// verifyOutput builds the output from the registered claims, which are read by every token format in its own way, and
+// the rest of the claims set.
+func verifyOutput(registered *RegisteredClaims, expiredAt, notBefore, issuedAt time.Time, set map[string]interface{}) *VerifyOutput {
+	var photo *string
+	if v, ok := set["picture"]; ok {
 		if s, vString := v.(string); vString {
 			photo = &s
 		}
 	}
 
+	registered.Scope = stringValue(set, "scope")
+	registered.ClientID = stringValue(set, "client_id")
+	registered.Actor = readActorClaim(set["act"])
+	if confirmation, ok := set["cnf"].(map[string]interface{}); ok {
+		registered.Confirmation = &Confirmation{JWKThumbprint: stringValue(confirmation, "jkt")}
+	}
+
 	return &VerifyOutput{
-		ExpiredAt: claims.Expires.Time(),
-		RegisteredClaims: &RegisteredClaims{
-			Issuer:         claims.Issuer,
-			Subject:        claims.Subject,
-			Audience:       claims.Audiences,
-			JsonWebTokenID: claims.ID,
-		},
+		ExpiredAt:        expiredAt,
+		NotBefore:        notBefore,
+		IssuedAt:         issuedAt,
+		RegisteredClaims: registered,
 		PublicClaims: &PublicClaims{
-			Name:                 stringValue(claims.Set, "name"),
-			GivenName:            stringValue(claims.Set, "given_name"),
-			FamilyName:           stringValue(claims.Set, "family_name"),
-			Email:                stringValue(claims.Set, "email"),
-			EmailVerified:        boolValue(claims.Set, "email_verified"),
+			Name:                 stringValue(set, "name"),
+			GivenName:            stringValue(set, "given_name"),
+			FamilyName:           stringValue(set, "family_name"),
+			Email:                stringValue(set, "email"),
+			EmailVerified:        boolValue(set, "email_verified"),
 			Picture:              photo,
-			PhoneNumber:          stringValue(claims.Set, "phone_number"),
-			PhoneNumberVerified:  boolValue(claims.Set, "phone_number_verified"),
+			PhoneNumber:          stringValue(set, "phone_number"),
+			PhoneNumberVerified:  boolValue(set, "phone_number_verified"),
 			AdditionalProperties: nil,
 		},
-		PrivateClaims: &PrivateClaims{},
-	}, nil
+		PrivateClaims: &PrivateClaims{Properties: customClaims(set)},
+	}
+}
+
+// reservedClaims are the claims set by the handler, the custom claims cannot override them.
+var reservedClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true,
+	"email": true, "name": true, "family_name": true, "email_verified": true, "given_name": true, "phone_number": true,
+	"phone_number_verified": true, "picture": true, "scope": true, "client_id": true, "act": true, "cnf": true,
+}
+
+func actorClaim(actor *Actor) map[string]interface{} {
+	result := map[string]interface{}{"sub": actor.Subject}
+	if actor.Actor != nil {
+		result["act"] = actorClaim(actor.Actor)
+	}
+
+	return result
+}
+
+func readActorClaim(value interface{}) *Actor {
+	claim, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	return &Actor{Subject: stringValue(claim, "sub"), Actor: readActorClaim(claim["act"])}
+}
+
+// customClaims returns the claims that are not reserved. There is no way to tell apart the additional public claims and
+// the private ones once the token is issued, all of them are returned as private claims.
+func customClaims(set map[string]interface{}) map[string]interface{} {
+	var result map[string]interface{}
+	for name, value := range set {
+		if reservedClaims[name] {
+			continue
+		}
+
+		if result == nil {
+			result = map[string]interface{}{}
+		}
+		result[name] = value
+	}
+
+	return result
+}
+
+// verificationKey returns the key referenced by the "kid" header. Tokens without "kid" were issued before the keyring
+// existed, those are verified with the active key. The algorithm of the token must be the one of the key, so the
+// algorithm cannot be switched by the token issuer.
+func (p PascalDeKloeJWTHandler) verificationKey(token string) (*SigningKey, error) {
+	header, err := parseTokenHeader(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var key *SigningKey
+	if header.KeyID == "" {
+		key, err = p.keyring.Active()
+	} else {
+		key, err = p.keyring.Key(header.KeyID)
+	}
+
+	if err != nil || header.Algorithm != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	return key, nil
+}
+
+func sign(claims *jwt.Claims, key *SigningKey) ([]byte, error) {
+	switch privateKey := key.PrivateKey.(type) {
+	case ed25519.PrivateKey:
+		return claims.EdDSASign(privateKey)
+	case *ecdsa.PrivateKey:
+		return claims.ECDSASign(key.Algorithm, privateKey)
+	case *rsa.PrivateKey:
+		return claims.RSASign(key.Algorithm, privateKey)
+	case []byte:
+		return claims.HMACSign(key.Algorithm, privateKey)
+	default:
+		return nil, ErrUnknownKey
+	}
+}
+
+func check(token []byte, key *SigningKey) (*jwt.Claims, error) {
+	switch publicKey := key.PublicKey.(type) {
+	case ed25519.PublicKey:
+		return jwt.EdDSACheck(token, publicKey)
+	case *ecdsa.PublicKey:
+		return jwt.ECDSACheck(token, publicKey)
+	case *rsa.PublicKey:
+		return jwt.RSACheck(token, publicKey)
+	case []byte:
+		return jwt.HMACCheck(token, publicKey)
+	default:
+		return nil, ErrUnknownKey
+	}
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ"`
+}
+
+func parseTokenHeader(token string) (*tokenHeader, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	header := &tokenHeader{}
+	if err = json.Unmarshal(content, header); err != nil {
+		return nil, err
+	}
+
+	return header, nil
 }
 
 type ObscureVerifyTokenInput struct {
@@ -309,19 +1318,36 @@ type IssueObscureTokenOutput struct {
 
 type ObscureUUIDTokenHandler struct {
 	idProvider      IDGenerator
-	stringGenerator StringGenerator
+	secretGenerator SecretGenerator
 }
 
+// NewObscureUUIDTokenHandler creates a handler whose secrets are alphanumeric strings with 256 bits of entropy.
 func NewObscureUUIDTokenHandler() *ObscureUUIDTokenHandler {
+	generator, err := random.NewGenerator(random.Alphanumeric, 256)
+	if err != nil {
+		panic(err)
+	}
+
+	return NewObscureUUIDTokenHandlerWithGenerator(generator.Read)
+}
+
+// NewObscureUUIDTokenHandlerWithGenerator creates a handler with the given secret generator. The secrets cannot
+// contain ":".
+func NewObscureUUIDTokenHandlerWithGenerator(generator SecretGenerator) *ObscureUUIDTokenHandler {
 	return &ObscureUUIDTokenHandler{
 		idProvider:      UUIDGenerator,
-		stringGenerator: random.Str,
+		secretGenerator: generator,
 	}
 }
 
 func (o ObscureUUIDTokenHandler) Issue(owner string) (*IssueObscureTokenOutput, error) {
+	secret, err := o.secretGenerator()
+	if err != nil {
+		return nil, err
+	}
+
 	return &IssueObscureTokenOutput{
-		ObscureToken: NewObscureToken(o.idProvider(), o.stringGenerator(450), owner),
+		ObscureToken: NewObscureToken(o.idProvider(), secret, owner),
 	}, nil
 }
 
@@ -331,7 +1357,8 @@ type ObscureToken struct {
 	subject string
 }
 
-type StringGenerator func(length int) string
+// SecretGenerator returns the random part of the obscure tokens.
+type SecretGenerator func() (string, error)
 
 type IDGenerator func() string
 
@@ -1,12 +1,35 @@
 package authentication_pool
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"github.com/pascaldekloe/jwt"
 	"reflect"
 	"testing"
 	"time"
 )
 
+func newTestKeyring(publicKey, privateKey []byte) *Keyring {
+	keyring := NewKeyring(time.Hour)
+	key, err := NewSigningKey("test-key", "EdDSA", ed25519.PublicKey(publicKey), ed25519.PrivateKey(privateKey))
+	if err != nil {
+		panic(err)
+	}
+	if err = keyring.Add(key); err != nil {
+		panic(err)
+	}
+	if err = keyring.Activate(key.ID); err != nil {
+		panic(err)
+	}
+
+	return keyring
+}
+
 func mockIDProvider() string {
 	return "generated.id"
 }
@@ -26,7 +49,6 @@ func TestPascalDeKloeJWTHandler_Issue(t *testing.T) {
 	}
 
 	type fields struct {
-		algorithm  string
 		publicKey  []byte
 		privateKey []byte
 	}
@@ -43,7 +65,6 @@ func TestPascalDeKloeJWTHandler_Issue(t *testing.T) {
 		{
 			name: "Token signed",
 			fields: fields{
-				algorithm:  "EdDSA",
 				publicKey:  publicKey,
 				privateKey: privateKey,
 			},
@@ -72,9 +93,7 @@ func TestPascalDeKloeJWTHandler_Issue(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			timeProvider := &mockTimeProvider{time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)}
 			p := PascalDeKloeJWTHandler{
-				algorithm:     tt.fields.algorithm,
-				publicKey:     tt.fields.publicKey,
-				privateKey:    tt.fields.privateKey,
+				keyring:       newTestKeyring(tt.fields.publicKey, tt.fields.privateKey),
 				timeProvider:  timeProvider.Now,
 				idProvider:    mockIDProvider,
 				timeToLive:    time.Minute * 5,
@@ -110,9 +129,7 @@ func TestPascalDeKloeJWTHandler_Verify(t *testing.T) {
 		panic(err)
 	}
 	prov := PascalDeKloeJWTHandler{
-		algorithm:     "EdDSA",
-		publicKey:     publicKey,
-		privateKey:    privateKey,
+		keyring:       newTestKeyring(publicKey, privateKey),
 		timeProvider:  mockTimeProvider{defaultTime}.Now,
 		idProvider:    mockIDProvider,
 		timeToLive:    time.Second * 10,
@@ -129,7 +146,6 @@ func TestPascalDeKloeJWTHandler_Verify(t *testing.T) {
 	}
 
 	type fields struct {
-		algorithm     string
 		publicKey     []byte
 		privateKey    []byte
 		timeProvider  timeProvider
@@ -149,7 +165,6 @@ func TestPascalDeKloeJWTHandler_Verify(t *testing.T) {
 		{
 			name: "is valid",
 			fields: fields{
-				algorithm:    "EdDSA",
 				publicKey:    publicKey,
 				privateKey:   privateKey,
 				timeProvider: mockTimeProvider{defaultTime}.Now,
@@ -170,7 +185,6 @@ func TestPascalDeKloeJWTHandler_Verify(t *testing.T) {
 		{
 			name: "Token is not valid",
 			fields: fields{
-				algorithm:    "EdDSA",
 				publicKey:    publicKey,
 				privateKey:   privateKey,
 				timeProvider: mockTimeProvider{}.Now,
@@ -183,9 +197,7 @@ func TestPascalDeKloeJWTHandler_Verify(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := PascalDeKloeJWTHandler{
-				algorithm:     tt.fields.algorithm,
-				publicKey:     tt.fields.publicKey,
-				privateKey:    tt.fields.privateKey,
+				keyring:       newTestKeyring(tt.fields.publicKey, tt.fields.privateKey),
 				timeProvider:  tt.fields.timeProvider,
 				idProvider:    mockIDProvider,
 				timeToLive:    tt.fields.timeToLive,
@@ -352,3 +364,573 @@ func TestObscureToken_Token(t *testing.T) {
 		})
 	}
 }
+
+func newTestJWTTokenProvider(issuedAt, now time.Time) *JWTTokenProvider {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return &JWTTokenProvider{
+		issuer:   "app",
+		audience: []string{"app-ID"},
+		jwtHandler: &PascalDeKloeJWTHandler{
+			keyring:      newTestKeyring(publicKey, privateKey),
+			timeProvider: mockTimeProvider{issuedAt}.Now,
+			idProvider:   UUIDGenerator,
+			timeToLive:   time.Minute * 10,
+		},
+		obscureHandler: NewObscureUUIDTokenHandler(),
+		timeProvider:   mockTimeProvider{now}.Now,
+		persistence:    NewInMemoryTokenPersistence(),
+	}
+}
+
+func TestJWTTokenProvider_Refresh(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+
+	t.Run("rotates the refresh token", func(t *testing.T) {
+		j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Hour))
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		got, err := j.Refresh(&RefreshTokenInput{
+			RefreshToken: tokens.RefreshToken.Token,
+			AccessToken:  tokens.AccessToken.Content,
+		})
+		if err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+
+		if got.RefreshToken == nil || got.RefreshToken.ID == tokens.RefreshToken.ID {
+			t.Errorf("Refresh() got = %v, want a new refresh token", got.RefreshToken)
+		}
+
+		previous, _ := j.persistence.Find(tokens.RefreshToken.ID)
+		if previous.Status != TokenRotated {
+			t.Errorf("Refresh() previous refresh token status = %v, want %v", previous.Status, TokenRotated)
+		}
+
+		if _, err = j.Refresh(&RefreshTokenInput{
+			RefreshToken: got.RefreshToken.Token,
+			AccessToken:  got.AccessToken.Content,
+		}); err != nil {
+			t.Errorf("Refresh() with the rotated token error = %v", err)
+		}
+	})
+
+	t.Run("revokes the family when a retired token is reused", func(t *testing.T) {
+		j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Hour))
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		rotated, err := j.Refresh(&RefreshTokenInput{
+			RefreshToken: tokens.RefreshToken.Token,
+			AccessToken:  tokens.AccessToken.Content,
+		})
+		if err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+
+		_, err = j.Refresh(&RefreshTokenInput{
+			RefreshToken: tokens.RefreshToken.Token,
+			AccessToken:  tokens.AccessToken.Content,
+		})
+		if err != ErrReusedToken {
+			t.Errorf("Refresh() error = %v, want %v", err, ErrReusedToken)
+		}
+
+		_, err = j.Refresh(&RefreshTokenInput{
+			RefreshToken: rotated.RefreshToken.Token,
+			AccessToken:  rotated.AccessToken.Content,
+		})
+		if err != ErrDisabledToken {
+			t.Errorf("Refresh() error = %v, want %v", err, ErrDisabledToken)
+		}
+
+		for _, id := range []string{tokens.AccessToken.ID, rotated.AccessToken.ID, rotated.RefreshToken.ID} {
+			if entity, _ := j.persistence.Find(id); entity.Status != TokenRevoked {
+				t.Errorf("Refresh() token %s status = %v, want %v", id, entity.Status, TokenRevoked)
+			}
+		}
+	})
+
+	t.Run("accepts only one of the concurrent refreshes", func(t *testing.T) {
+		j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Hour))
+		j.persistence = &slowTokenPersistence{NewInMemoryTokenPersistence()}
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := j.Refresh(&RefreshTokenInput{
+					RefreshToken: tokens.RefreshToken.Token,
+					AccessToken:  tokens.AccessToken.Content,
+				})
+				errs <- err
+			}()
+		}
+
+		reused := 0
+		for i := 0; i < 2; i++ {
+			if err := <-errs; err == ErrReusedToken {
+				reused++
+			} else if err != nil {
+				t.Errorf("Refresh() error = %v", err)
+			}
+		}
+
+		if reused == 0 {
+			t.Errorf("Refresh() got no %v", ErrReusedToken)
+		}
+
+		if sessions, _ := j.persistence.FindSessions("customer"); len(sessions) != 0 {
+			t.Errorf("Refresh() left %d refresh tokens enabled, want 0", len(sessions))
+		}
+	})
+
+	t.Run("rejects a refresh token with another secret", func(t *testing.T) {
+		j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Hour))
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		forged := NewObscureToken("", "forged", "customer")
+		forged.id = tokens.RefreshToken.ID
+		_, err = j.Refresh(&RefreshTokenInput{
+			RefreshToken: forged.Token(),
+			AccessToken:  tokens.AccessToken.Content,
+		})
+		if err != ErrInvalidToken {
+			t.Errorf("Refresh() error = %v, want %v", err, ErrInvalidToken)
+		}
+	})
+}
+
+// slowTokenPersistence adds latency after the reads, so the concurrent calls read the same status.
+type slowTokenPersistence struct {
+	*InMemoryTokenPersistence
+}
+
+func (s *slowTokenPersistence) Find(tokenID string) (*Entity, error) {
+	entity, err := s.InMemoryTokenPersistence.Find(tokenID)
+	time.Sleep(time.Millisecond * 10)
+	return entity, err
+}
+
+func TestJWTTokenProvider_Logout(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt)
+	j.statefulVerify = true
+
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	if _, err = j.Verify(tokens.AccessToken.Content); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	if err = j.Logout(tokens.AccessToken.Content); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+
+	if _, err = j.Verify(tokens.AccessToken.Content); err != ErrDisabledToken {
+		t.Errorf("Verify() error = %v, want %v", err, ErrDisabledToken)
+	}
+
+	if refreshToken, _ := j.persistence.Find(tokens.RefreshToken.ID); refreshToken.Status != TokenRevoked {
+		t.Errorf("Logout() refresh token status = %v, want %v", refreshToken.Status, TokenRevoked)
+	}
+
+	t.Run("revokes the whole family", func(t *testing.T) {
+		first, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exchanged, err := j.Exchange(&TokenExchangeInput{SubjectToken: first.AccessToken.Content, Actor: "gateway"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		j.timeProvider = mockTimeProvider{issuedAt.Add(time.Hour)}.Now
+		second, err := j.Refresh(&RefreshTokenInput{RefreshToken: first.RefreshToken.Token, AccessToken: first.AccessToken.Content})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = j.Logout(second.AccessToken.Content); err != nil {
+			t.Fatalf("Logout() error = %v", err)
+		}
+
+		for _, id := range []string{first.AccessToken.ID, first.RefreshToken.ID, exchanged.AccessToken.ID, second.RefreshToken.ID} {
+			if entity, _ := j.persistence.Find(id); entity.Status != TokenRevoked {
+				t.Errorf("Logout() status of %v = %v, want %v", id, entity.Status, TokenRevoked)
+			}
+		}
+	})
+}
+
+func TestJWTTokenProvider_RevokeAllForUser(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt)
+	j.statefulVerify = true
+
+	first, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	second, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	other, err := j.CreateToken(&CreateTokenInput{ID: "other"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	if err = j.RevokeAllForUser("customer"); err != nil {
+		t.Fatalf("RevokeAllForUser() error = %v", err)
+	}
+
+	for _, token := range []*Token{first.AccessToken, second.AccessToken} {
+		if _, err = j.Verify(token.Content); err != ErrDisabledToken {
+			t.Errorf("Verify() error = %v, want %v", err, ErrDisabledToken)
+		}
+	}
+
+	if _, err = j.Verify(other.AccessToken.Content); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestPascalDeKloeJWTHandler_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	secret := []byte("a secret that is long enough for HS256")
+
+	tests := []struct {
+		name       string
+		algorithm  string
+		privateKey crypto.PrivateKey
+	}{
+		{name: "RS256", algorithm: "RS256", privateKey: rsaKey},
+		{name: "RS384", algorithm: "RS384", privateKey: rsaKey},
+		{name: "RS512", algorithm: "RS512", privateKey: rsaKey},
+		{name: "ES384", algorithm: "ES384", privateKey: ecdsaKey},
+		{name: "HS256", algorithm: "HS256", privateKey: secret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewSigningKey("key", tt.algorithm, nil, tt.privateKey)
+			if err != nil {
+				t.Fatalf("NewSigningKey() error = %v", err)
+			}
+
+			keyring := NewKeyring(0)
+			_ = keyring.Add(key)
+			_ = keyring.Activate(key.ID)
+			p := NewPascalDeKloeJWTHandlerWithKeyring(keyring, time.Minute, 0)
+
+			output, err := p.Issue(&IssueInput{RegisteredClaims: RegisteredClaims{Subject: "customer"}})
+			if err != nil {
+				t.Fatalf("Issue() error = %v", err)
+			}
+
+			header, _ := parseTokenHeader(output.Token.Content)
+			if header.Algorithm != tt.algorithm {
+				t.Errorf("Issue() alg = %v, want %v", header.Algorithm, tt.algorithm)
+			}
+
+			got, err := p.Verify(&VerifyInput{Token: output.Token.Content})
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.RegisteredClaims.Subject != "customer" {
+				t.Errorf("Verify() subject = %v, want customer", got.RegisteredClaims.Subject)
+			}
+		})
+	}
+
+	t.Run("rejects a token that switches the algorithm", func(t *testing.T) {
+		key, err := NewSigningKey("key", "RS256", nil, rsaKey)
+		if err != nil {
+			t.Fatalf("NewSigningKey() error = %v", err)
+		}
+
+		keyring := NewKeyring(0)
+		_ = keyring.Add(key)
+		_ = keyring.Activate(key.ID)
+		p := NewPascalDeKloeJWTHandlerWithKeyring(keyring, time.Minute, 0)
+
+		publicKey, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+		claims := jwt.Claims{KeyID: key.ID, Registered: jwt.Registered{Subject: "attacker"}}
+		forged, err := claims.HMACSign("HS256", publicKey)
+		if err != nil {
+			panic(err)
+		}
+
+		if _, err = p.Verify(&VerifyInput{Token: string(forged)}); err != ErrInvalidToken {
+			t.Errorf("Verify() error = %v, want %v", err, ErrInvalidToken)
+		}
+	})
+}
+
+func TestJWTTokenProvider_Verify(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(now, now)
+	j.leeway = time.Second * 30
+	handler := j.jwtHandler.(*PascalDeKloeJWTHandler)
+
+	issue := func(issuer string, audience []string, issuedAt time.Time, timeToBeValid time.Duration) string {
+		p := *handler
+		p.timeProvider = mockTimeProvider{issuedAt}.Now
+		p.timeToBeValid = timeToBeValid
+		output, err := p.Issue(&IssueInput{RegisteredClaims: RegisteredClaims{
+			Issuer:   issuer,
+			Subject:  "customer",
+			Audience: audience,
+		}})
+		if err != nil {
+			panic(err)
+		}
+
+		return output.Token.Content
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "is valid",
+			token: issue("app", []string{"other-app", "app-ID"}, now, 0),
+		},
+		{
+			name:  "is valid within the leeway",
+			token: issue("app", []string{"app-ID"}, now.Add(time.Second*20), 0),
+		},
+		{
+			name:    "was issued by another issuer",
+			token:   issue("another-app", []string{"app-ID"}, now, 0),
+			wantErr: ErrInvalidIssuer,
+		},
+		{
+			name:    "was issued for another audience",
+			token:   issue("app", []string{"other-app"}, now, 0),
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name:    "is not valid yet",
+			token:   issue("app", []string{"app-ID"}, now, time.Minute),
+			wantErr: ErrTokenNotValidYet,
+		},
+		{
+			name:    "was issued in the future",
+			token:   issue("app", []string{"app-ID"}, now.Add(time.Minute), -time.Minute),
+			wantErr: ErrTokenIssuedInFuture,
+		},
+		{
+			name:    "has expired",
+			token:   issue("app", []string{"app-ID"}, now.Add(-time.Minute*11), 0),
+			wantErr: ErrExpiredToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := j.Verify(tt.token)
+			if err != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTTokenProvider_EnrichClaims(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Hour))
+
+	calls := 0
+	j.claimsEnricher = func(input *CreateTokenInput) (map[string]interface{}, error) {
+		calls++
+		return map[string]interface{}{"tenant_id": "acme", "roles": []interface{}{"admin"}, "calls": float64(calls)}, nil
+	}
+
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer", Email: "customer@example.com"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	got, err := j.jwtHandler.Verify(&VerifyInput{tokens.AccessToken.Content})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	want := map[string]interface{}{"tenant_id": "acme", "roles": []interface{}{"admin"}, "calls": float64(1)}
+	if !reflect.DeepEqual(got.PrivateClaims.Properties, want) {
+		t.Errorf("Verify() got = %v, want %v", got.PrivateClaims.Properties, want)
+	}
+
+	refreshed, err := j.Refresh(&RefreshTokenInput{
+		RefreshToken: tokens.RefreshToken.Token,
+		AccessToken:  tokens.AccessToken.Content,
+	})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if got, err = j.jwtHandler.Verify(&VerifyInput{refreshed.AccessToken.Content}); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	if got.PrivateClaims.Properties["calls"] != float64(2) || got.PublicClaims.Email != "customer@example.com" {
+		t.Errorf("Refresh() did not call the enricher again, got = %v", got.PrivateClaims.Properties)
+	}
+
+	j.claimsEnricher = func(input *CreateTokenInput) (map[string]interface{}, error) {
+		return map[string]interface{}{"sub": "admin"}, nil
+	}
+
+	if _, err = j.CreateToken(&CreateTokenInput{ID: "customer"}); err == nil {
+		t.Errorf("CreateToken() overriding a reserved claim error = nil")
+	}
+}
+
+func TestJWTTokenProvider_SessionLifetime(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+
+	newProvider := func() (*JWTTokenProvider, *fixedTimeProvider) {
+		clock := newFixedTimeProvider(issuedAt.Add(time.Minute * 11))
+		j := newTestJWTTokenProvider(issuedAt, issuedAt)
+		j.timeProvider = clock.Now
+		j.idleTimeout = time.Hour
+		j.absoluteTimeout = time.Hour * 3
+		return j, clock
+	}
+
+	t.Run("extends the session up to the absolute limit", func(t *testing.T) {
+		j, clock := newProvider()
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		if want := issuedAt.Add(time.Minute * 71); tokens.RefreshToken.ExpireAt == nil || !tokens.RefreshToken.ExpireAt.Equal(want) {
+			t.Errorf("CreateToken() refresh token ExpireAt = %v, want %v", tokens.RefreshToken.ExpireAt, want)
+		}
+
+		accessToken, refreshToken := tokens.AccessToken, tokens.RefreshToken
+		sessionEnd := issuedAt.Add(time.Minute * 191)
+		for _, elapsed := range []time.Duration{time.Hour, time.Minute * 110, time.Minute * 160} {
+			clock.now = issuedAt.Add(elapsed)
+			got, err := j.Refresh(&RefreshTokenInput{RefreshToken: refreshToken.Token, AccessToken: accessToken.Content})
+			if err != nil {
+				t.Fatalf("Refresh() after %v error = %v", elapsed, err)
+			}
+
+			want := clock.now.Add(time.Hour)
+			if want.After(sessionEnd) {
+				want = sessionEnd
+			}
+			if !got.RefreshToken.ExpireAt.Equal(want) {
+				t.Errorf("Refresh() after %v refresh token ExpireAt = %v, want %v", elapsed, got.RefreshToken.ExpireAt, want)
+			}
+
+			accessToken, refreshToken = got.AccessToken, got.RefreshToken
+		}
+
+		clock.now = sessionEnd
+		_, err = j.Refresh(&RefreshTokenInput{RefreshToken: refreshToken.Token, AccessToken: accessToken.Content})
+		if err != ErrExpiredToken {
+			t.Errorf("Refresh() after the absolute limit error = %v, want %v", err, ErrExpiredToken)
+		}
+	})
+
+	t.Run("rejects an idle session", func(t *testing.T) {
+		j, clock := newProvider()
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		clock.now = clock.now.Add(time.Hour)
+		_, err = j.Refresh(&RefreshTokenInput{RefreshToken: tokens.RefreshToken.Token, AccessToken: tokens.AccessToken.Content})
+		if err != ErrExpiredToken {
+			t.Errorf("Refresh() error = %v, want %v", err, ErrExpiredToken)
+		}
+	})
+}
+
+func TestJWTTokenProvider_RefreshWindow(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		wantErr error
+	}{
+		{name: "before the window", elapsed: time.Minute * 7, wantErr: ErrTokenNotExpired},
+		{name: "in the window", elapsed: time.Minute * 9, wantErr: nil},
+		{name: "expired", elapsed: time.Minute * 11, wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(tt.elapsed))
+			j.refreshWindow = 0.2
+			tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+			if err != nil {
+				t.Fatalf("CreateToken() error = %v", err)
+			}
+
+			_, err = j.Refresh(&RefreshTokenInput{RefreshToken: tokens.RefreshToken.Token, AccessToken: tokens.AccessToken.Content})
+			if err != tt.wantErr {
+				t.Errorf("Refresh() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTTokenProvider_AllowRefreshTokenOnly(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Minute))
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer", Email: "customer@example.com"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	if _, err = j.Refresh(&RefreshTokenInput{RefreshToken: tokens.RefreshToken.Token}); err != ErrInvalidToken {
+		t.Errorf("Refresh() without the option error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	j.refreshTokenOnly = true
+	got, err := j.Refresh(&RefreshTokenInput{RefreshToken: tokens.RefreshToken.Token})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	claims, err := j.jwtHandler.Verify(&VerifyInput{got.AccessToken.Content})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	if claims.RegisteredClaims.Subject != "customer" || claims.PublicClaims.Email != "customer@example.com" {
+		t.Errorf("Refresh() got claims = %v, %v", claims.RegisteredClaims, claims.PublicClaims)
+	}
+}
@@ -0,0 +1,356 @@
+package authentication_pool
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/pascaldekloe/jwt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("the given signing key is not available")
+
+var ErrNoActiveKey = errors.New("there is no active signing key")
+
+type KeyStatus string
+
+const (
+	// KeyActive is the status of the key used to sign the new tokens. There is only one active key at a time.
+	KeyActive KeyStatus = "active"
+	// KeyVerifyOnly is the status of a key that verifies tokens but does not sign new ones.
+	KeyVerifyOnly KeyStatus = "verify-only"
+	// KeyRetired is the status of a key that verifies tokens only during the keyring grace period.
+	KeyRetired KeyStatus = "retired"
+)
+
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	// PublicKey is an ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey or the []byte secret for HMAC.
+	PublicKey crypto.PublicKey
+	// PrivateKey is an ed25519.PrivateKey, *ecdsa.PrivateKey, *rsa.PrivateKey or the []byte secret for HMAC. Keys
+	// without PrivateKey can only verify tokens.
+	PrivateKey crypto.PrivateKey
+	Status     KeyStatus
+	RetiredAt  *time.Time
+}
+
+// NewSigningKey creates a verify only key for the given algorithm. If the public key is nil it is derived from the
+// private key. If the ID is empty it is derived from the public key, HMAC keys must have an ID.
+func NewSigningKey(ID string, algorithm string, publicKey crypto.PublicKey, privateKey crypto.PrivateKey) (*SigningKey, error) {
+	if publicKey == nil {
+		switch key := privateKey.(type) {
+		case []byte:
+			publicKey = key
+		case ed25519.PrivateKey:
+			// Public panics with the keys of another size, they are rejected by validateKey.
+			if len(key) == ed25519.PrivateKeySize {
+				publicKey = key.Public()
+			} else {
+				publicKey = ed25519.PublicKey(nil)
+			}
+		case crypto.Signer:
+			publicKey = key.Public()
+		}
+	}
+
+	if err := validateKey(algorithm, publicKey, privateKey); err != nil {
+		return nil, err
+	}
+
+	if ID == "" {
+		if _, isSecret := publicKey.([]byte); isSecret {
+			return nil, errors.New("the HMAC keys need an ID")
+		}
+
+		var err error
+		if ID, err = KeyID(publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SigningKey{
+		ID:         ID,
+		Algorithm:  algorithm,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+		Status:     KeyVerifyOnly,
+	}, nil
+}
+
+// NewSigningKeyFromPEM reads the first key in the PEM data. Private keys (PKCS #1, PKCS #8, SEC 1) can sign and
+// verify tokens, public keys (PKIX, PKCS #1) and certificates can only verify tokens.
+func NewSigningKeyFromPEM(ID string, algorithm string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("the given data does not contain a PEM block")
+	}
+
+	var (
+		publicKey  crypto.PublicKey
+		privateKey crypto.PrivateKey
+		err        error
+	)
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var certificate *x509.Certificate
+		if certificate, err = x509.ParseCertificate(block.Bytes); err == nil {
+			publicKey = certificate.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("the PEM block %q is not supported", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(ID, algorithm, publicKey, privateKey)
+}
+
+// validateKey checks that the given keys can be used with the algorithm.
+func validateKey(algorithm string, publicKey crypto.PublicKey, privateKey crypto.PrivateKey) error {
+	if _, ok := jwt.HMACAlgs[algorithm]; ok {
+		secret, ok := publicKey.([]byte)
+		if !ok || len(secret) < 32 {
+			return fmt.Errorf("the algorithm %s needs a secret of at least 32 bytes", algorithm)
+		}
+
+		return nil
+	}
+
+	if _, isSecret := privateKey.([]byte); isSecret {
+		return fmt.Errorf("the algorithm %s cannot use a secret", algorithm)
+	}
+
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		if algorithm != jwt.EdDSA {
+			return fmt.Errorf("the algorithm %s cannot use an Ed25519 key", algorithm)
+		}
+		if err := validateEd25519Key(key, privateKey); err != nil {
+			return err
+		}
+	case *rsa.PublicKey:
+		if _, ok := jwt.RSAAlgs[algorithm]; !ok {
+			return fmt.Errorf("the algorithm %s cannot use a RSA key", algorithm)
+		}
+		if key.N.BitLen() < 2048 {
+			return errors.New("the RSA keys must have at least 2048 bits")
+		}
+	case *ecdsa.PublicKey:
+		if curve, ok := ecdsaCurves[algorithm]; !ok || curve != key.Curve {
+			return fmt.Errorf("the algorithm %s cannot use an ECDSA %s key", algorithm, key.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("the algorithm %s is not supported", algorithm)
+	}
+
+	return nil
+}
+
+// validateEd25519Key checks the size of the keys, the signing would panic otherwise, and that the private key belongs
+// to the public key.
+func validateEd25519Key(publicKey ed25519.PublicKey, privateKey crypto.PrivateKey) error {
+	var private ed25519.PrivateKey
+	if privateKey != nil {
+		var ok bool
+		if private, ok = privateKey.(ed25519.PrivateKey); !ok || len(private) != ed25519.PrivateKeySize {
+			return fmt.Errorf("the Ed25519 private keys must have %d bytes", ed25519.PrivateKeySize)
+		}
+	}
+
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("the Ed25519 public keys must have %d bytes", ed25519.PublicKeySize)
+	}
+
+	if private != nil && !bytes.Equal(private[ed25519.SeedSize:], publicKey) {
+		return errors.New("the Ed25519 private key does not belong to the public key")
+	}
+
+	return nil
+}
+
+var ecdsaCurves = map[string]elliptic.Curve{
+	jwt.ES256: elliptic.P256(),
+	jwt.ES384: elliptic.P384(),
+	jwt.ES512: elliptic.P521(),
+}
+
+// KeyID returns a stable identifier for the given public key.
+func KeyID(publicKey crypto.PublicKey) (string, error) {
+	content, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// keyIDLabel is the message signed by the HMAC secrets to derive their identifiers.
+const keyIDLabel = "authentication-pool key ID"
+
+// KeyIDFromSecret returns a stable identifier for the given HMAC secret. The identifier is published in the tokens, so
+// it is the HMAC of a fixed label made with the secret, and not a plain hash of the secret.
+func KeyIDFromSecret(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(keyIDLabel))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Keyring holds the keys used to sign and verify the tokens. The rotation of a key is done in three steps: the new key
+// is added, so it can verify tokens. Then it's activated, so it signs the new tokens, the previous key stays as verify
+// only. At the end the previous key is retired, it keeps verifying tokens during the grace period.
+type Keyring struct {
+	keys         map[string]*SigningKey
+	active       string
+	gracePeriod  time.Duration
+	timeProvider timeProvider
+	mx           sync.RWMutex
+}
+
+func NewKeyring(gracePeriod time.Duration) *Keyring {
+	return &Keyring{
+		keys:         map[string]*SigningKey{},
+		gracePeriod:  gracePeriod,
+		timeProvider: osTimeProvider,
+	}
+}
+
+// Add registers the given key as verify only. If there is a key with the same ID returns ErrDuplicatedEntityExists.
+func (k *Keyring) Add(key *SigningKey) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	if _, ok := k.keys[key.ID]; ok {
+		return ErrDuplicatedEntityExists
+	}
+
+	key.Status = KeyVerifyOnly
+	key.RetiredAt = nil
+	k.keys[key.ID] = key
+	return nil
+}
+
+// Activate makes the given key the one that signs the new tokens, the previous active key becomes verify only.
+func (k *Keyring) Activate(keyID string) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	key, ok := k.keys[keyID]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	if key.PrivateKey == nil {
+		return errors.New("the given key cannot sign tokens")
+	}
+
+	if previous, ok := k.keys[k.active]; ok && previous.ID != keyID {
+		previous.Status = KeyVerifyOnly
+	}
+
+	key.Status = KeyActive
+	key.RetiredAt = nil
+	k.active = keyID
+	return nil
+}
+
+// Retire stops using the given key once the grace period is over. The active key cannot be retired.
+func (k *Keyring) Retire(keyID string) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	key, ok := k.keys[keyID]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	if key.Status == KeyActive {
+		return errors.New("the active key cannot be retired")
+	}
+
+	if key.Status == KeyRetired {
+		return nil
+	}
+
+	now := k.timeProvider()
+	key.Status = KeyRetired
+	key.RetiredAt = &now
+	return nil
+}
+
+// Active returns the key used to sign the new tokens.
+func (k *Keyring) Active() (*SigningKey, error) {
+	k.mx.RLock()
+	defer k.mx.RUnlock()
+
+	key, ok := k.keys[k.active]
+	if !ok {
+		return nil, ErrNoActiveKey
+	}
+
+	return key, nil
+}
+
+// Key returns the key with the given ID if it can verify tokens. Retired keys are returned only during the grace
+// period.
+func (k *Keyring) Key(keyID string) (*SigningKey, error) {
+	k.mx.RLock()
+	defer k.mx.RUnlock()
+
+	key, ok := k.keys[keyID]
+	if !ok || !k.usable(key) {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+// Keys returns the keys that can verify tokens sorted by ID.
+func (k *Keyring) Keys() []*SigningKey {
+	k.mx.RLock()
+	defer k.mx.RUnlock()
+
+	result := make([]*SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		if k.usable(key) {
+			result = append(result, key)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (k *Keyring) usable(key *SigningKey) bool {
+	if key.Status != KeyRetired {
+		return true
+	}
+
+	return key.RetiredAt != nil && k.timeProvider().Before(key.RetiredAt.Add(k.gracePeriod))
+}
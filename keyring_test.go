@@ -0,0 +1,276 @@
+package authentication_pool
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func newTestSigningKey(ID string) *SigningKey {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	key, err := NewSigningKey(ID, "EdDSA", publicKey, privateKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(time.Hour)
+	keyring.timeProvider = mockTimeProvider{now}.Now
+
+	handler := NewPascalDeKloeJWTHandlerWithKeyring(keyring, time.Minute, 0)
+	handler.timeProvider = keyring.timeProvider
+
+	first, second := newTestSigningKey("first"), newTestSigningKey("second")
+	for _, key := range []*SigningKey{first, second} {
+		if err := keyring.Add(key); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	if _, err := handler.Issue(&IssueInput{}); err != ErrNoActiveKey {
+		t.Errorf("Issue() error = %v, want %v", err, ErrNoActiveKey)
+	}
+
+	if err := keyring.Activate(first.ID); err != nil {
+		t.Fatalf("Activate() error = %v", err)
+	}
+
+	oldToken, err := handler.Issue(&IssueInput{})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	if err = keyring.Activate(second.ID); err != nil {
+		t.Fatalf("Activate() error = %v", err)
+	}
+	if first.Status != KeyVerifyOnly {
+		t.Errorf("Activate() previous key status = %v, want %v", first.Status, KeyVerifyOnly)
+	}
+
+	newToken, err := handler.Issue(&IssueInput{})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	header, err := parseTokenHeader(newToken.Token.Content)
+	if err != nil || header.KeyID != second.ID {
+		t.Errorf("Issue() kid = %v, want %v", header, second.ID)
+	}
+
+	if err = keyring.Retire(second.ID); err == nil {
+		t.Errorf("Retire() the active key must fail")
+	}
+	if err = keyring.Retire(first.ID); err != nil {
+		t.Fatalf("Retire() error = %v", err)
+	}
+
+	for _, token := range []*IssueOutput{oldToken, newToken} {
+		if _, err = handler.Verify(&VerifyInput{Token: token.Token.Content}); err != nil {
+			t.Errorf("Verify() during the grace period error = %v", err)
+		}
+	}
+
+	keyring.timeProvider = mockTimeProvider{now.Add(time.Hour)}.Now
+	if _, err = handler.Verify(&VerifyInput{Token: oldToken.Token.Content}); err != ErrInvalidToken {
+		t.Errorf("Verify() after the grace period error = %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err = handler.Verify(&VerifyInput{Token: newToken.Token.Content}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	if keys := keyring.Keys(); len(keys) != 1 || keys[0].ID != second.ID {
+		t.Errorf("Keys() = %v, want only %v", keys, second.ID)
+	}
+}
+
+func TestNewSigningKeyFromPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	ecdsaContent, err := x509.MarshalECPrivateKey(ecdsaKey)
+	if err != nil {
+		panic(err)
+	}
+	publicContent, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		block     *pem.Block
+		canSign   bool
+		wantErr   bool
+	}{
+		{
+			name:      "RSA private key",
+			algorithm: "RS256",
+			block:     &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+			canSign:   true,
+		},
+		{
+			name:      "ECDSA private key",
+			algorithm: "ES256",
+			block:     &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecdsaContent},
+			canSign:   true,
+		},
+		{
+			name:      "RSA public key",
+			algorithm: "RS512",
+			block:     &pem.Block{Type: "PUBLIC KEY", Bytes: publicContent},
+			canSign:   false,
+		},
+		{
+			name:      "ECDSA key with another curve",
+			algorithm: "ES384",
+			block:     &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecdsaContent},
+			wantErr:   true,
+		},
+		{
+			name:      "RSA key with an ECDSA algorithm",
+			algorithm: "ES256",
+			block:     &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSigningKeyFromPEM("", tt.algorithm, pem.EncodeToMemory(tt.block))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewSigningKeyFromPEM() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if (got.PrivateKey != nil) != tt.canSign {
+				t.Errorf("NewSigningKeyFromPEM() private key = %v, want %v", got.PrivateKey != nil, tt.canSign)
+			}
+			if got.ID == "" || got.PublicKey == nil {
+				t.Errorf("NewSigningKeyFromPEM() got = %v", got)
+			}
+		})
+	}
+}
+
+func TestNewSigningKey_Ed25519(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+	anotherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name       string
+		publicKey  crypto.PublicKey
+		privateKey crypto.PrivateKey
+		wantErr    bool
+	}{
+		{name: "key pair", publicKey: publicKey, privateKey: privateKey},
+		{name: "derived public key", privateKey: privateKey},
+		{name: "public key only", publicKey: publicKey},
+		{name: "short private key", publicKey: publicKey, privateKey: privateKey[:ed25519.SeedSize], wantErr: true},
+		{name: "derived from a short private key", privateKey: privateKey[:16], wantErr: true},
+		{name: "short public key", publicKey: publicKey[:16], wantErr: true},
+		{name: "private key of another public key", publicKey: anotherPublicKey, privateKey: privateKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSigningKey("", "EdDSA", tt.publicKey, tt.privateKey); (err != nil) != tt.wantErr {
+				t.Errorf("NewSigningKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyIDFromSecret(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("authentication-pool key ID"))
+	want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	if got := KeyIDFromSecret(secret); got != want {
+		t.Errorf("KeyIDFromSecret() got = %v, want %v", got, want)
+	}
+
+	if KeyIDFromSecret([]byte("fedcba9876543210fedcba9876543210")) == want {
+		t.Errorf("KeyIDFromSecret() got the same ID for another secret")
+	}
+}
+
+func TestNewPascalDeKloeJWTHandler_VerifyOnly(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	publicContent, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		panic(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicContent})
+
+	signer, err := NewPascalDeKloeJWTHandler("RS256", nil, privateKey, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("NewPascalDeKloeJWTHandler() error = %v", err)
+	}
+
+	issued, err := signer.Issue(&IssueInput{RegisteredClaims: RegisteredClaims{Subject: "customer"}})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		publicKey  []byte
+		privateKey []byte
+	}{
+		{name: "public key", publicKey: publicKey},
+		{name: "public key given as private key", privateKey: publicKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewPascalDeKloeJWTHandler("RS256", tt.publicKey, tt.privateKey, time.Minute, 0)
+			if err != nil {
+				t.Fatalf("NewPascalDeKloeJWTHandler() error = %v", err)
+			}
+
+			if _, err = verifier.Verify(&VerifyInput{Token: issued.Token.Content}); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+
+			if _, err = verifier.Issue(&IssueInput{RegisteredClaims: RegisteredClaims{Subject: "customer"}}); err != ErrNoActiveKey {
+				t.Errorf("Issue() error = %v, want %v", err, ErrNoActiveKey)
+			}
+		})
+	}
+}
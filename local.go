@@ -197,6 +197,22 @@ func (g LocalProvider) ValidateSignUp(input *SignUpInput) (*ValidateSignUpOutput
 		return &ValidateSignUpOutput{Err: err}, nil
 	}
 
+	// The user is not registered when the account cannot be synchronized, otherwise the email would stay taken.
+	if checker, ok := g.synchronizer.(SynchronizationChecker); ok {
+		err = checker.CheckSynchronization(&SynchronizeInput{
+			Provider:      g.Name(),
+			Email:         input.Email,
+			EmailVerified: input.Validated,
+		})
+		if _, ok := err.(*ValidationInputFailed); ok {
+			return &ValidateSignUpOutput{Err: err}, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &ValidateSignUpOutput{}, nil
 }
 
@@ -226,9 +242,10 @@ func (g LocalProvider) SignUp(input *SignUpInput) (*SignUpOutput, error) {
 	}
 
 	syncOutput, err := g.synchronizer.Synchronize(&SynchronizeInput{
-		Provider: g.Name(),
-		ID:       output.ID,
-		Email:    input.Email,
+		Provider:      g.Name(),
+		ID:            output.ID,
+		Email:         input.Email,
+		EmailVerified: input.Validated,
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,300 @@
+package authentication_pool
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lapix-com-co/authentication-pool/verifier"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCProvider validates the id_tokens of an OpenID Connect issuer. The keys are found with the discovery document of
+// the issuer and cached, the tokens are verified locally without calling the issuer on every login. The email is only
+// verified when the token has the email_verified claim, the other emails are not linked to the existing accounts.
+type OIDCProvider struct {
+	name      string
+	issuer    string
+	clientIDs []string
+	client    *http.Client
+	leeway    time.Duration
+	skipNonce bool
+	// jwksURI skips the discovery for the issuers that do not publish it.
+	jwksURI      string
+	timeProvider timeProvider
+
+	verifier *verifier.Verifier
+	// discovering is closed when the discovery in progress finishes, it is nil when there is none. The last failed
+	// discovery is kept in discoveryErr along with its time.
+	discovering  chan struct{}
+	discoveryErr error
+	attemptedAt  time.Time
+	mx           sync.Mutex
+}
+
+// discoveryRetryInterval is the time between two discoveries when the issuer is not available.
+const discoveryRetryInterval = time.Minute
+
+// defaultOIDCHTTPClient gives up on the issuers that do not answer, so a hung issuer does not block the logins.
+var defaultOIDCHTTPClient = &http.Client{Timeout: time.Second * 10}
+
+type OIDCProviderOptions func(p *OIDCProvider) error
+
+// OIDCHTTPClient sets the client used to fetch the discovery document and the keys, a client with a timeout of ten
+// seconds is used by default.
+func OIDCHTTPClient(client *http.Client) OIDCProviderOptions {
+	return func(p *OIDCProvider) error {
+		if client == nil {
+			return errors.New("the HTTP client cannot be nil")
+		}
+
+		p.client = client
+		return nil
+	}
+}
+
+// OIDCLeeway accepts id_tokens whose time claims are off by the given duration.
+func OIDCLeeway(leeway time.Duration) OIDCProviderOptions {
+	return func(p *OIDCProvider) error {
+		if leeway < 0 {
+			return errors.New("the leeway cannot be negative")
+		}
+
+		p.leeway = leeway
+		return nil
+	}
+}
+
+// OIDCSkipNonce accepts the logins without nonce, the nonce is required by default because it stops the replay of a
+// leaked id_token. The nonce is still checked when it is given.
+func OIDCSkipNonce() OIDCProviderOptions {
+	return func(p *OIDCProvider) error {
+		p.skipNonce = true
+		return nil
+	}
+}
+
+// NewOIDCProvider creates a provider named name that accepts the id_tokens issued by the issuer to one of the given
+// clients. The discovery document is fetched on the first login.
+func NewOIDCProvider(name, issuer string, clientIDs []string, opts ...OIDCProviderOptions) (*OIDCProvider, error) {
+	if name == "" {
+		return nil, errors.New("the provider name cannot be empty")
+	}
+
+	if !strings.HasPrefix(issuer, "https://") && !strings.HasPrefix(issuer, "http://") {
+		return nil, errors.New("the issuer must be an URL")
+	}
+
+	if len(clientIDs) == 0 {
+		return nil, errors.New("at least one client ID is required")
+	}
+
+	provider := &OIDCProvider{
+		name:         name,
+		issuer:       issuer,
+		clientIDs:    clientIDs,
+		client:       defaultOIDCHTTPClient,
+		timeProvider: osTimeProvider,
+	}
+
+	for _, opt := range opts {
+		if err := opt(provider); err != nil {
+			return nil, err
+		}
+	}
+
+	return provider, nil
+}
+
+// Retrieve verifies the id_token given as secret: its signature, issuer, audience, expiration and nonce.
+func (o *OIDCProvider) Retrieve(input *ValidationInput) (*ValidationOutput, error) {
+	claims, err := o.verify(input.Secret, input.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.validationOutput(claims), nil
+}
+
+func (o *OIDCProvider) Name() string {
+	return o.name
+}
+
+func (o *OIDCProvider) verify(idToken, nonce string) (*verifier.Claims, error) {
+	tokenVerifier, err := o.tokenVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := tokenVerifier.Verify(idToken)
+	if err != nil && !isVerifierTokenError(err) {
+		// The keys could not be fetched.
+		return nil, NewProviderError(err, "invalid response from server. Please try again")
+	}
+	if err != nil {
+		return nil, NewValidationInputFailed("the given token is not valid")
+	}
+
+	if !o.acceptAuthorizedParty(claims) || !o.acceptNonce(claims, nonce) {
+		return nil, NewValidationInputFailed("the given token is not valid")
+	}
+
+	return claims, nil
+}
+
+func isVerifierTokenError(err error) bool {
+	switch err {
+	case verifier.ErrInvalidToken, verifier.ErrExpiredToken, verifier.ErrTokenNotValidYet, verifier.ErrTokenIssuedInFuture,
+		verifier.ErrInvalidIssuer, verifier.ErrInvalidAudience, verifier.ErrUnknownKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// acceptAuthorizedParty checks the azp claim, it is the client the token was issued to when there are several
+// audiences.
+func (o *OIDCProvider) acceptAuthorizedParty(claims *verifier.Claims) bool {
+	party, ok := claims.Extra["azp"].(string)
+	if !ok {
+		return len(claims.Audience) == 1
+	}
+
+	for _, clientID := range o.clientIDs {
+		if clientID == party {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *OIDCProvider) acceptNonce(claims *verifier.Claims, nonce string) bool {
+	given, _ := claims.Extra["nonce"].(string)
+	if nonce == "" {
+		return o.skipNonce
+	}
+
+	return subtle.ConstantTimeCompare([]byte(given), []byte(nonce)) == 1
+}
+
+func (o *OIDCProvider) validationOutput(claims *verifier.Claims) *ValidationOutput {
+	output := &ValidationOutput{
+		ID:             claims.Subject,
+		FirstName:      claims.GivenName,
+		LastName:       claims.FamilyName,
+		Email:          claims.Email,
+		EmailValidated: claims.EmailVerified,
+	}
+
+	// Some issuers only send the full name.
+	if output.FirstName == "" && output.LastName == "" {
+		output.FirstName = claims.Name
+	}
+
+	if claims.Picture != "" {
+		picture := claims.Picture
+		output.PhotoURL = &picture
+	}
+
+	return output
+}
+
+type openIDProviderMetadata struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// tokenVerifier returns the verifier of the issuer, the discovery document is fetched until it is read once. The
+// concurrent logins wait for the same discovery, and after a failure the issuer is not called again until
+// discoveryRetryInterval has passed.
+func (o *OIDCProvider) tokenVerifier() (*verifier.Verifier, error) {
+	o.mx.Lock()
+	if o.verifier != nil {
+		defer o.mx.Unlock()
+		return o.verifier, nil
+	}
+
+	if o.discovering != nil {
+		done := o.discovering
+		o.mx.Unlock()
+		<-done
+
+		o.mx.Lock()
+		defer o.mx.Unlock()
+		return o.verifier, o.discoveryErr
+	}
+
+	now := o.timeProvider()
+	if o.discoveryErr != nil && now.Before(o.attemptedAt.Add(discoveryRetryInterval)) {
+		defer o.mx.Unlock()
+		return nil, o.discoveryErr
+	}
+
+	done := make(chan struct{})
+	o.discovering = done
+	o.attemptedAt = now
+	jwksURI := o.jwksURI
+	o.mx.Unlock()
+
+	// The issuer is called without holding the lock.
+	tokenVerifier, err := o.newVerifier(jwksURI)
+
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	o.verifier, o.discoveryErr = tokenVerifier, err
+	o.discovering = nil
+	close(done)
+
+	return tokenVerifier, err
+}
+
+// newVerifier creates the verifier with the given JWKS endpoint, when it is empty the endpoint is discovered.
+func (o *OIDCProvider) newVerifier(jwksURI string) (*verifier.Verifier, error) {
+	if jwksURI == "" {
+		metadata, err := o.discover()
+		if err != nil {
+			return nil, NewProviderError(err, "invalid response from server. Please try again")
+		}
+
+		jwksURI = metadata.JWKSURI
+	}
+
+	keys, err := verifier.NewRemoteKeySet(jwksURI, verifier.HTTPClient(o.client))
+	if err != nil {
+		return nil, err
+	}
+
+	return verifier.New(o.issuer, o.clientIDs, keys, verifier.Leeway(o.leeway))
+}
+
+func (o *OIDCProvider) discover() (*openIDProviderMetadata, error) {
+	response, err := o.client.Get(strings.TrimSuffix(o.issuer, "/") + OpenIDConfigurationPath)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the discovery endpoint returned the status %d", response.StatusCode)
+	}
+
+	metadata := &openIDProviderMetadata{}
+	if err = json.NewDecoder(response.Body).Decode(metadata); err != nil {
+		return nil, err
+	}
+
+	// The issuer of the document must be the configured one, otherwise the document could be replaced.
+	if metadata.Issuer != o.issuer {
+		return nil, fmt.Errorf("the discovery document belongs to the issuer %q", metadata.Issuer)
+	}
+
+	if metadata.JWKSURI == "" {
+		return nil, errors.New("the discovery document has no jwks_uri")
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,206 @@
+package authentication_pool
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"github.com/pascaldekloe/jwt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testOIDCIssuer struct {
+	server      *httptest.Server
+	issuer      string
+	privateKey  ed25519.PrivateKey
+	discoveries int32
+	// hold delays the discovery until it is closed.
+	hold chan struct{}
+}
+
+func newTestOIDCIssuer(t *testing.T) *testOIDCIssuer {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &testOIDCIssuer{privateKey: privateKey}
+	i.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case OpenIDConfigurationPath:
+			atomic.AddInt32(&i.discoveries, 1)
+			if i.hold != nil {
+				<-i.hold
+			}
+			respondJSON(w, http.StatusOK, map[string]string{"issuer": i.issuer, "jwks_uri": i.server.URL + "/keys"})
+		case "/keys":
+			respondJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+				"kty": "OKP", "crv": "Ed25519", "kid": "key-1", "x": base64.RawURLEncoding.EncodeToString(publicKey),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	i.issuer = i.server.URL
+
+	return i
+}
+
+func (i *testOIDCIssuer) idToken(audience []string, set map[string]interface{}) string {
+	now := time.Now().Truncate(time.Second)
+	claims := &jwt.Claims{
+		Registered: jwt.Registered{
+			Issuer:    i.server.URL,
+			Subject:   "248289761001",
+			Audiences: audience,
+			Issued:    jwt.NewNumericTime(now),
+			Expires:   jwt.NewNumericTime(now.Add(time.Hour)),
+		},
+		KeyID: "key-1",
+		Set:   set,
+	}
+
+	token, err := claims.EdDSASign(i.privateKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return string(token)
+}
+
+func TestOIDCProvider_Retrieve(t *testing.T) {
+	issuer := newTestOIDCIssuer(t)
+	defer issuer.server.Close()
+	claims := map[string]interface{}{
+		"nonce": "n-0S6_WzA2Mj", "email": "jane@example.com", "email_verified": true, "given_name": "Jane",
+		"family_name": "Doe", "picture": "https://example.com/jane.jpg",
+	}
+
+	provider, err := NewOIDCProvider("example", issuer.issuer, []string{"client-1"})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error = %v", err)
+	}
+
+	got, err := provider.Retrieve(&ValidationInput{Secret: issuer.idToken([]string{"client-1"}, claims), Nonce: "n-0S6_WzA2Mj"})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	picture := "https://example.com/jane.jpg"
+	want := NewValidationOutput("248289761001", "Jane", "Doe", "jane@example.com", &picture, true)
+	if got.ID != want.ID || got.FirstName != want.FirstName || got.LastName != want.LastName ||
+		got.Email != want.Email || *got.PhotoURL != *want.PhotoURL || got.EmailValidated != want.EmailValidated {
+		t.Errorf("Retrieve() got = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		input *ValidationInput
+	}{
+		{name: "another nonce", input: &ValidationInput{Secret: issuer.idToken([]string{"client-1"}, claims), Nonce: "another"}},
+		{name: "without nonce", input: &ValidationInput{Secret: issuer.idToken([]string{"client-1"}, claims)}},
+		{
+			name:  "another client",
+			input: &ValidationInput{Secret: issuer.idToken([]string{"client-2"}, claims), Nonce: "n-0S6_WzA2Mj"},
+		},
+		{
+			name:  "several audiences without authorized party",
+			input: &ValidationInput{Secret: issuer.idToken([]string{"client-1", "client-2"}, claims), Nonce: "n-0S6_WzA2Mj"},
+		},
+		{name: "not a token", input: &ValidationInput{Secret: "not a token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.Retrieve(tt.input); err == nil {
+				t.Errorf("Retrieve() error = nil")
+			} else if _, ok := err.(*ValidationInputFailed); !ok {
+				t.Errorf("Retrieve() error = %v, want a validation error", err)
+			}
+		})
+	}
+
+	t.Run("authorized party", func(t *testing.T) {
+		token := issuer.idToken([]string{"client-1", "client-2"}, map[string]interface{}{"azp": "client-1", "nonce": "n-1"})
+		if _, err := provider.Retrieve(&ValidationInput{Secret: token, Nonce: "n-1"}); err != nil {
+			t.Errorf("Retrieve() error = %v", err)
+		}
+	})
+
+	t.Run("skipped nonce", func(t *testing.T) {
+		provider, _ := NewOIDCProvider("example", issuer.issuer, []string{"client-1"}, OIDCSkipNonce())
+		if _, err := provider.Retrieve(&ValidationInput{Secret: issuer.idToken([]string{"client-1"}, claims)}); err != nil {
+			t.Errorf("Retrieve() without nonce error = %v", err)
+		}
+
+		if _, err := provider.Retrieve(&ValidationInput{Secret: issuer.idToken([]string{"client-1"}, claims), Nonce: "another"}); err == nil {
+			t.Errorf("Retrieve() with another nonce error = nil")
+		}
+	})
+}
+
+func TestOIDCProvider_Discovery(t *testing.T) {
+	issuer := newTestOIDCIssuer(t)
+	defer issuer.server.Close()
+	token := issuer.idToken([]string{"client-1"}, nil)
+	issuer.issuer = "https://another.example.com"
+
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	provider, _ := NewOIDCProvider("example", issuer.server.URL, []string{"client-1"}, OIDCSkipNonce())
+	provider.timeProvider = func() time.Time { return now }
+	if _, err := provider.Retrieve(&ValidationInput{Secret: token}); err == nil {
+		t.Errorf("Retrieve() error = nil")
+	} else if _, ok := err.(*ProviderError); !ok {
+		t.Errorf("Retrieve() error = %v, want a provider error", err)
+	}
+
+	// The failed discovery is not retried before the interval.
+	issuer.issuer = issuer.server.URL
+	if _, err := provider.Retrieve(&ValidationInput{Secret: token}); err == nil || atomic.LoadInt32(&issuer.discoveries) != 1 {
+		t.Errorf("Retrieve() error = %v, discoveries = %d, want the previous error", err, issuer.discoveries)
+	}
+
+	now = now.Add(discoveryRetryInterval)
+	if _, err := provider.Retrieve(&ValidationInput{Secret: token}); err != nil {
+		t.Errorf("Retrieve() error = %v", err)
+	}
+}
+
+func TestOIDCProvider_ConcurrentDiscovery(t *testing.T) {
+	issuer := newTestOIDCIssuer(t)
+	defer issuer.server.Close()
+	issuer.hold = make(chan struct{})
+	token := issuer.idToken([]string{"client-1"}, nil)
+
+	provider, _ := NewOIDCProvider("example", issuer.server.URL, []string{"client-1"}, OIDCSkipNonce())
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, err := provider.Retrieve(&ValidationInput{Secret: token})
+			errs <- err
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	close(issuer.hold)
+	for i := 0; i < 5; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Retrieve() error = %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&issuer.discoveries); got != 1 {
+		t.Errorf("Retrieve() discoveries = %d, want 1", got)
+	}
+}
+
+func TestNewOIDCProvider(t *testing.T) {
+	if _, err := NewOIDCProvider("example", "accounts.example.com", []string{"client-1"}); err == nil {
+		t.Errorf("NewOIDCProvider() without URL error = nil")
+	}
+
+	if _, err := NewOIDCProvider("example", "https://accounts.example.com", nil); err == nil {
+		t.Errorf("NewOIDCProvider() without clients error = nil")
+	}
+}
@@ -0,0 +1,241 @@
+package authentication_pool
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var _ JWTHandler = &PasetoV4PublicHandler{}
+
+const pasetoV4PublicHeader = "v4.public."
+
+// PasetoV4PublicHandler issues and verifies PASETO v4.public tokens. The tokens are signed with Ed25519, the version
+// defines the algorithm so it cannot be chosen by the token. The footer carries the "kid" of the signing key.
+type PasetoV4PublicHandler struct {
+	keyring       *Keyring
+	timeProvider  timeProvider
+	idProvider    IDGenerator
+	timeToLive    time.Duration
+	timeToBeValid time.Duration
+}
+
+// NewPasetoV4PublicHandler creates a handler that signs with the active key of the keyring, the keys must be Ed25519.
+func NewPasetoV4PublicHandler(keyring *Keyring, timeToLive time.Duration, timeToBeValid time.Duration) *PasetoV4PublicHandler {
+	return &PasetoV4PublicHandler{
+		keyring:       keyring,
+		timeProvider:  osTimeProvider,
+		idProvider:    UUIDGenerator,
+		timeToLive:    timeToLive,
+		timeToBeValid: timeToBeValid,
+	}
+}
+
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+func (p PasetoV4PublicHandler) Issue(input *IssueInput) (*IssueOutput, error) {
+	now := p.timeProvider()
+	timeToLive := p.timeToLive
+	if input.TimeToLive != 0 {
+		timeToLive = input.TimeToLive
+	}
+	expireAt := now.Add(timeToLive)
+	input.RegisteredClaims.JsonWebTokenID = fmt.Sprintf("%s:%s", input.RegisteredClaims.Subject, p.idProvider())
+
+	key, err := p.keyring.Active()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.PrivateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("the PASETO v4.public tokens need an Ed25519 key")
+	}
+
+	claims, err := claimsSet(input)
+	if err != nil {
+		return nil, err
+	}
+
+	claims["iss"] = input.RegisteredClaims.Issuer
+	claims["sub"] = input.RegisteredClaims.Subject
+	claims["jti"] = input.RegisteredClaims.JsonWebTokenID
+	claims["iat"] = now.UTC().Format(time.RFC3339)
+	claims["exp"] = expireAt.UTC().Format(time.RFC3339)
+	claims["nbf"] = now.Add(p.timeToBeValid).UTC().Format(time.RFC3339)
+	if len(input.RegisteredClaims.Audience) == 1 {
+		claims["aud"] = input.RegisteredClaims.Audience[0]
+	} else if len(input.RegisteredClaims.Audience) > 1 {
+		claims["aud"] = input.RegisteredClaims.Audience
+	}
+
+	message, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	footer, err := json.Marshal(&pasetoFooter{KeyID: key.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	signature := ed25519.Sign(privateKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil))
+	token := pasetoV4PublicHeader + base64.RawURLEncoding.EncodeToString(append(message, signature...)) + "." +
+		base64.RawURLEncoding.EncodeToString(footer)
+
+	return &IssueOutput{
+		Token: &Token{
+			ID:         input.RegisteredClaims.JsonWebTokenID,
+			TokenType:  "Bearer",
+			Content:    token,
+			ExpireAt:   expireAt,
+			TimeToLive: int64(timeToLive),
+		},
+		CreatedAt: now,
+	}, nil
+}
+
+func (p PasetoV4PublicHandler) Verify(input *VerifyInput) (*VerifyOutput, error) {
+	if !strings.HasPrefix(input.Token, pasetoV4PublicHeader) {
+		return nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(strings.TrimPrefix(input.Token, pasetoV4PublicHeader), ".")
+	if len(parts) > 2 {
+		return nil, ErrInvalidToken
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(content) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+
+	var footer []byte
+	if len(parts) == 2 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	publicKey, err := p.verificationKey(footer)
+	if err != nil {
+		return nil, err
+	}
+
+	message, signature := content[:len(content)-ed25519.SignatureSize], content[len(content)-ed25519.SignatureSize:]
+	if !ed25519.Verify(publicKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	claims := map[string]interface{}{}
+	if err = json.Unmarshal(message, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	expiredAt, err := pasetoTime(claims, "exp")
+	if err != nil {
+		return nil, err
+	}
+	notBefore, err := pasetoTime(claims, "nbf")
+	if err != nil {
+		return nil, err
+	}
+	issuedAt, err := pasetoTime(claims, "iat")
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyOutput(&RegisteredClaims{
+		Issuer:         stringValue(claims, "iss"),
+		Subject:        stringValue(claims, "sub"),
+		Audience:       pasetoAudience(claims),
+		JsonWebTokenID: stringValue(claims, "jti"),
+	}, expiredAt, notBefore, issuedAt, claims), nil
+}
+
+// verificationKey returns the key referenced by the footer, the tokens without footer are verified with the active key.
+func (p PasetoV4PublicHandler) verificationKey(footer []byte) (ed25519.PublicKey, error) {
+	content := &pasetoFooter{}
+	if len(footer) > 0 {
+		if err := json.Unmarshal(footer, content); err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	var (
+		key *SigningKey
+		err error
+	)
+	if content.KeyID == "" {
+		key, err = p.keyring.Active()
+	} else {
+		key, err = p.keyring.Key(content.KeyID)
+	}
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	publicKey, ok := key.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return publicKey, nil
+}
+
+// pae is the Pre-Authentication Encoding of PASETO, it encodes the pieces so they cannot be confused with each other.
+func pae(pieces ...[]byte) []byte {
+	buffer := &bytes.Buffer{}
+	length := make([]byte, 8)
+
+	binary.LittleEndian.PutUint64(length, uint64(len(pieces))&^(1<<63))
+	buffer.Write(length)
+	for _, piece := range pieces {
+		binary.LittleEndian.PutUint64(length, uint64(len(piece))&^(1<<63))
+		buffer.Write(length)
+		buffer.Write(piece)
+	}
+
+	return buffer.Bytes()
+}
+
+func pasetoTime(claims map[string]interface{}, name string) (time.Time, error) {
+	value := stringValue(claims, name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidToken
+	}
+
+	return result, nil
+}
+
+// pasetoAudience reads the "aud" claim, PASETO defines it as a string but a list is accepted too.
+func pasetoAudience(claims map[string]interface{}) []string {
+	switch audience := claims["aud"].(type) {
+	case string:
+		return []string{audience}
+	case []interface{}:
+		result := make([]string, 0, len(audience))
+		for _, value := range audience {
+			if s, ok := value.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
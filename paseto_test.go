@@ -0,0 +1,128 @@
+package authentication_pool
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPasetoHandler(issuedAt time.Time) *PasetoV4PublicHandler {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return &PasetoV4PublicHandler{
+		keyring:      newTestKeyring(publicKey, privateKey),
+		timeProvider: mockTimeProvider{issuedAt}.Now,
+		idProvider:   UUIDGenerator,
+		timeToLive:   time.Minute * 10,
+	}
+}
+
+func TestPasetoV4PublicHandler_Verify(t *testing.T) {
+	t.Run("verifies the test vector 4-S-1 of the specification", func(t *testing.T) {
+		secret, _ := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+		keyring := NewKeyring(0)
+		key, err := NewSigningKey("vector", "EdDSA", nil, ed25519.PrivateKey(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = keyring.Add(key)
+		_ = keyring.Activate(key.ID)
+
+		p := NewPasetoV4PublicHandler(keyring, time.Minute, 0)
+		got, err := p.Verify(&VerifyInput{"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"})
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+
+		if !got.ExpiredAt.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) || got.PrivateClaims.Properties["data"] != "this is a signed message" {
+			t.Errorf("Verify() got = %v, %v", got.ExpiredAt, got.PrivateClaims.Properties)
+		}
+	})
+
+	t.Run("round trips the claims", func(t *testing.T) {
+		issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+		p := newTestPasetoHandler(issuedAt)
+		issued, err := p.Issue(&IssueInput{
+			RegisteredClaims: RegisteredClaims{Issuer: "app", Subject: "customer", Audience: []string{"app-ID"}, Scope: "orders:read"},
+			PublicClaims:     PublicClaims{Email: "customer@example.com", EmailVerified: true},
+			PrivateClaims:    PrivateClaims{Properties: map[string]interface{}{"tenant_id": "acme"}},
+		})
+		if err != nil {
+			t.Fatalf("Issue() error = %v", err)
+		}
+
+		if !strings.HasPrefix(issued.Token.Content, "v4.public.") {
+			t.Errorf("Issue() got = %v", issued.Token.Content)
+		}
+
+		got, err := p.Verify(&VerifyInput{issued.Token.Content})
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+
+		if got.RegisteredClaims.Issuer != "app" || got.RegisteredClaims.Subject != "customer" ||
+			len(got.RegisteredClaims.Audience) != 1 || got.RegisteredClaims.Audience[0] != "app-ID" ||
+			got.RegisteredClaims.JsonWebTokenID != issued.Token.ID || got.RegisteredClaims.Scope != "orders:read" {
+			t.Errorf("Verify() got registered claims = %v", got.RegisteredClaims)
+		}
+		if got.PublicClaims.Email != "customer@example.com" || !got.PublicClaims.EmailVerified {
+			t.Errorf("Verify() got public claims = %v", got.PublicClaims)
+		}
+		if got.PrivateClaims.Properties["tenant_id"] != "acme" {
+			t.Errorf("Verify() got private claims = %v", got.PrivateClaims.Properties)
+		}
+		if !got.IssuedAt.Equal(issuedAt) || !got.ExpiredAt.Equal(issuedAt.Add(time.Minute*10)) {
+			t.Errorf("Verify() got times = %v, %v", got.IssuedAt, got.ExpiredAt)
+		}
+	})
+
+	t.Run("rejects a tampered token", func(t *testing.T) {
+		p := newTestPasetoHandler(time.Now())
+		issued, err := p.Issue(&IssueInput{RegisteredClaims: RegisteredClaims{Subject: "customer"}})
+		if err != nil {
+			t.Fatalf("Issue() error = %v", err)
+		}
+
+		content := issued.Token.Content
+		replacement := "A"
+		if content[15] == 'A' {
+			replacement = "B"
+		}
+		tampered := content[:15] + replacement + content[16:]
+
+		for _, token := range []string{tampered, strings.Replace(content, "v4.", "v3.", 1), "v4.public.AAAA"} {
+			if _, err = p.Verify(&VerifyInput{token}); err != ErrInvalidToken {
+				t.Errorf("Verify(%s) error = %v, want %v", token, err, ErrInvalidToken)
+			}
+		}
+	})
+}
+
+func TestJWTTokenProvider_Paseto(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Minute))
+	j.jwtHandler = newTestPasetoHandler(issuedAt)
+
+	tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer", Email: "customer@example.com"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	got, err := j.Verify(tokens.AccessToken.Content)
+	if err != nil || !got.Valid || *got.CustomerEmail != "customer@example.com" {
+		t.Errorf("Verify() got = %v, %v", got, err)
+	}
+
+	j.timeProvider = mockTimeProvider{issuedAt.Add(time.Hour)}.Now
+	if _, err = j.Refresh(&RefreshTokenInput{
+		RefreshToken: tokens.RefreshToken.Token,
+		AccessToken:  tokens.AccessToken.Content,
+	}); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+}
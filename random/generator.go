@@ -0,0 +1,81 @@
+package random
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+)
+
+const (
+	Digits       = "0123456789"
+	Letters      = letterBytes
+	Alphanumeric = Digits + Letters
+	// URLSafe is the alphabet of the base64 URL encoding.
+	URLSafe = Alphanumeric + "-_"
+)
+
+// Generator creates random strings from crypto/rand. The length of the strings is the minimum that reaches the
+// requested entropy with the alphabet.
+type Generator struct {
+	alphabet string
+	length   int
+}
+
+// NewGenerator creates a generator of strings with at least the given bits of entropy. The alphabet must have at least
+// two different characters.
+func NewGenerator(alphabet string, bits int) (*Generator, error) {
+	if bits <= 0 {
+		return nil, errors.New("the entropy must be greater than zero")
+	}
+
+	seen := map[rune]bool{}
+	for _, c := range alphabet {
+		if seen[c] {
+			return nil, errors.New("the alphabet cannot have repeated characters")
+		}
+		seen[c] = true
+	}
+
+	if len(seen) < 2 {
+		return nil, errors.New("the alphabet must have at least two characters")
+	}
+
+	return &Generator{
+		alphabet: alphabet,
+		length:   int(math.Ceil(float64(bits) / math.Log2(float64(len(seen))))),
+	}, nil
+}
+
+// Length returns the number of characters of the generated strings.
+func (g *Generator) Length() int {
+	return g.length
+}
+
+// Read returns a new random string.
+func (g *Generator) Read() (string, error) {
+	alphabet := []rune(g.alphabet)
+	size := big.NewInt(int64(len(alphabet)))
+	result := make([]rune, g.length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+
+		result[i] = alphabet[n.Int64()]
+	}
+
+	return string(result), nil
+}
+
+// Generate returns a new random string, it panics when the system random source fails. It can be used as a
+// codes.Generator.
+func (g *Generator) Generate() string {
+	result, err := g.Read()
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
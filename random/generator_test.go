@@ -0,0 +1,52 @@
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewGenerator(t *testing.T) {
+	tests := []struct {
+		name       string
+		alphabet   string
+		bits       int
+		wantLength int
+		wantErr    bool
+	}{
+		{name: "alphanumeric", alphabet: Alphanumeric, bits: 256, wantLength: 43},
+		{name: "digits", alphabet: Digits, bits: 20, wantLength: 7},
+		{name: "url safe", alphabet: URLSafe, bits: 128, wantLength: 22},
+		{name: "without entropy", alphabet: Digits, bits: 0, wantErr: true},
+		{name: "a single character", alphabet: "a", bits: 8, wantErr: true},
+		{name: "repeated characters", alphabet: "aab", bits: 8, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGenerator(tt.alphabet, tt.bits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGenerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if g.Length() != tt.wantLength {
+				t.Errorf("Length() got = %v, want %v", g.Length(), tt.wantLength)
+			}
+
+			got, err := g.Read()
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			if len(got) != tt.wantLength || strings.Trim(got, tt.alphabet) != "" {
+				t.Errorf("Read() got = %v", got)
+			}
+
+			if got == g.Generate() {
+				t.Errorf("Generate() returned the same string twice")
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ const (
 	letterIdxMax = 63 / letterIdxBits
 )
 
+// Deprecated: Str uses math/rand, the strings are predictable. Use a Generator instead.
 func Str(n int) string {
 	b := make([]byte, n)
 	for i, cache, remain := n-1, rand.Int63(), letterIdxMax; i >= 0; {
@@ -6,6 +6,8 @@ const (
 	Google   ProviderName = "google"
 	Facebook              = "facebook"
 	Local                 = "local"
+	Apple                 = "apple"
+	GitHub                = "github"
 )
 
 type ProviderFactory struct {
@@ -0,0 +1,143 @@
+package authentication_pool
+
+import "sort"
+
+var _ SessionManager = &JWTTokenProvider{}
+
+func (j JWTTokenProvider) Sessions(userID string) ([]*Session, error) {
+	entities, err := j.activeSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Session, 0, len(entities))
+	for _, entity := range entities {
+		session := *entity.Session
+		result = append(result, &session)
+	}
+
+	sort.Slice(result, func(a, b int) bool { return result[a].LastUsedAt.After(result[b].LastUsedAt) })
+	return result, nil
+}
+
+// RevokeSession revokes the whole family of the refresh token of the session, so the access tokens issued in the session
+// are revoked too.
+func (j JWTTokenProvider) RevokeSession(userID, sessionID string) error {
+	entities, err := j.activeSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, entity := range entities {
+		if entity.Session.ID == sessionID {
+			return j.revokeFamily(entity)
+		}
+	}
+
+	return ErrNotFound
+}
+
+// activeSessions returns the refresh tokens of the user that have not expired.
+func (j JWTTokenProvider) activeSessions(userID string) ([]*Entity, error) {
+	entities, err := j.persistence.FindSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Entity, 0, len(entities))
+	for _, entity := range entities {
+		if entity.Session == nil || (entity.ExpiredAt != nil && !j.validTime(*entity.ExpiredAt)) {
+			continue
+		}
+
+		result = append(result, entity)
+	}
+
+	return result, nil
+}
+
+// SessionLimitPolicy is the number of sessions that a user can hold at the same time. The limits are checked when a
+// token is created, the refresh of a session is always allowed.
+type SessionLimitPolicy struct {
+	// MaxSessions is the limit of sessions per user, zero means no limit.
+	MaxSessions int
+	// MaxSessionsPerDeviceClass is the limit of sessions per user of each device class. The device classes that are not
+	// in the map, or whose limit is zero, have no limit.
+	MaxSessionsPerDeviceClass map[string]int
+	// EvictOldest revokes the least recently used sessions to make room for the new one. Otherwise CreateToken returns
+	// ErrSessionLimitReached.
+	EvictOldest bool
+}
+
+// enforceSessionLimit makes room for the session of the given input. The limit of the device class is applied first, the
+// evicted sessions count for the limit of the user. The refresh token of the new session, when it has been persisted
+// already, is skipped.
+func (j JWTTokenProvider) enforceSessionLimit(input *CreateTokenInput, refreshTokenID string) error {
+	if j.sessionLimit == nil {
+		return nil
+	}
+
+	active, err := j.activeSessions(input.ID)
+	if err != nil {
+		return err
+	}
+
+	entities := make([]*Entity, 0, len(active))
+	for _, entity := range active {
+		if entity.ID != refreshTokenID {
+			entities = append(entities, entity)
+		}
+	}
+
+	sort.Slice(entities, func(a, b int) bool {
+		return entities[a].Session.LastUsedAt.Before(entities[b].Session.LastUsedAt)
+	})
+
+	if max, ok := j.sessionLimit.MaxSessionsPerDeviceClass[input.DeviceClass]; ok {
+		sameClass := make([]*Entity, 0)
+		for _, entity := range entities {
+			if entity.Session.DeviceClass == input.DeviceClass {
+				sameClass = append(sameClass, entity)
+			}
+		}
+
+		evicted, err := j.evictSessions(sameClass, max)
+		if err != nil {
+			return err
+		}
+
+		remaining := make([]*Entity, 0, len(entities))
+		for _, entity := range entities {
+			if !evicted[entity.ID] {
+				remaining = append(remaining, entity)
+			}
+		}
+		entities = remaining
+	}
+
+	_, err = j.evictSessions(entities, j.sessionLimit.MaxSessions)
+	return err
+}
+
+// evictSessions revokes the oldest sessions until there is room for a new one. The given sessions must be sorted from
+// the least recently used. It returns the IDs of the revoked refresh tokens.
+func (j JWTTokenProvider) evictSessions(entities []*Entity, max int) (map[string]bool, error) {
+	evicted := map[string]bool{}
+	if max == 0 || len(entities) < max {
+		return evicted, nil
+	}
+
+	if !j.sessionLimit.EvictOldest {
+		return nil, ErrSessionLimitReached
+	}
+
+	for _, entity := range entities[:len(entities)-max+1] {
+		if err := j.revokeFamily(entity); err != nil {
+			return nil, err
+		}
+
+		evicted[entity.ID] = true
+	}
+
+	return evicted, nil
+}
@@ -0,0 +1,174 @@
+package authentication_pool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJWTTokenProvider_Sessions(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	clock := newFixedTimeProvider(issuedAt)
+	j := newTestJWTTokenProvider(issuedAt, issuedAt)
+	j.timeProvider = clock.Now
+	sessionIDs := 0
+	j.idProvider = func() string {
+		sessionIDs++
+		return fmt.Sprintf("session-%d", sessionIDs)
+	}
+
+	phone, err := j.CreateToken(&CreateTokenInput{ID: "customer", UserAgent: "phone", IPAddress: "10.0.0.1", Provider: "google"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	clock.now = issuedAt.Add(time.Minute)
+	laptop, err := j.CreateToken(&CreateTokenInput{ID: "customer", UserAgent: "laptop", IPAddress: "10.0.0.2", Provider: "local"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	clock.now = issuedAt.Add(time.Hour)
+	refreshed, err := j.Refresh(&RefreshTokenInput{RefreshToken: phone.RefreshToken.Token, AccessToken: phone.AccessToken.Content})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	sessions, err := j.Sessions("customer")
+	if err != nil {
+		t.Fatalf("Sessions() error = %v", err)
+	}
+
+	if len(sessions) != 2 {
+		t.Fatalf("Sessions() got %d sessions, want 2", len(sessions))
+	}
+
+	if got := sessions[0]; got.ID != "session-1" || got.UserAgent != "phone" || got.IPAddress != "10.0.0.1" || got.Provider != "google" ||
+		!got.CreatedAt.Equal(issuedAt) || !got.LastUsedAt.Equal(issuedAt.Add(time.Hour)) {
+		t.Errorf("Sessions() got = %v, want the refreshed phone session first", got)
+	}
+
+	if got := sessions[1]; got.ID != "session-2" || got.UserAgent != "laptop" || !got.LastUsedAt.Equal(issuedAt.Add(time.Minute)) {
+		t.Errorf("Sessions() got = %v, want the laptop session", got)
+	}
+
+	if err = j.RevokeSession("another-customer", sessions[0].ID); err != ErrNotFound {
+		t.Errorf("RevokeSession() of another user error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err = j.RevokeSession("customer", sessions[0].ID); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+
+	for _, id := range []string{refreshed.AccessToken.ID, refreshed.RefreshToken.ID, phone.AccessToken.ID} {
+		if entity, _ := j.persistence.Find(id); entity.Status != TokenRevoked {
+			t.Errorf("RevokeSession() token %s status = %v, want %v", id, entity.Status, TokenRevoked)
+		}
+	}
+
+	if entity, _ := j.persistence.Find(laptop.RefreshToken.ID); entity.Status != TokenEnabled {
+		t.Errorf("RevokeSession() revoked another session")
+	}
+
+	if sessions, _ = j.Sessions("customer"); len(sessions) != 1 {
+		t.Errorf("Sessions() got %d sessions, want 1", len(sessions))
+	}
+}
+
+func TestJWTTokenProvider_SessionLimit(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+
+	newProvider := func(policy SessionLimitPolicy) (*JWTTokenProvider, *fixedTimeProvider) {
+		clock := newFixedTimeProvider(issuedAt)
+		j := newTestJWTTokenProvider(issuedAt, issuedAt)
+		j.timeProvider = clock.Now
+		j.sessionLimit = &policy
+		return j, clock
+	}
+
+	login := func(j *JWTTokenProvider, clock *fixedTimeProvider, deviceClass string) (*CreateTokenOutput, error) {
+		clock.now = clock.now.Add(time.Minute)
+		return j.CreateToken(&CreateTokenInput{ID: "customer", DeviceClass: deviceClass})
+	}
+
+	t.Run("refuses a new session", func(t *testing.T) {
+		j, clock := newProvider(SessionLimitPolicy{MaxSessions: 2})
+		for i := 0; i < 2; i++ {
+			if _, err := login(j, clock, "web"); err != nil {
+				t.Fatalf("CreateToken() error = %v", err)
+			}
+		}
+
+		if _, err := login(j, clock, "web"); err != ErrSessionLimitReached {
+			t.Errorf("CreateToken() error = %v, want %v", err, ErrSessionLimitReached)
+		}
+	})
+
+	t.Run("evicts the oldest session", func(t *testing.T) {
+		j, clock := newProvider(SessionLimitPolicy{MaxSessions: 2, EvictOldest: true})
+		oldest, err := login(j, clock, "web")
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err = login(j, clock, "web"); err != nil {
+				t.Fatalf("CreateToken() error = %v", err)
+			}
+		}
+
+		if entity, _ := j.persistence.Find(oldest.RefreshToken.ID); entity.Status != TokenRevoked {
+			t.Errorf("CreateToken() oldest session status = %v, want %v", entity.Status, TokenRevoked)
+		}
+
+		if sessions, _ := j.Sessions("customer"); len(sessions) != 2 {
+			t.Errorf("Sessions() got %d sessions, want 2", len(sessions))
+		}
+	})
+
+	t.Run("keeps the sessions when the login fails", func(t *testing.T) {
+		j, clock := newProvider(SessionLimitPolicy{MaxSessions: 1, EvictOldest: true})
+		oldest, err := login(j, clock, "web")
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		j.claimsEnricher = func(input *CreateTokenInput) (map[string]interface{}, error) {
+			return nil, errors.New("the claims are not available")
+		}
+		if _, err = login(j, clock, "web"); err == nil {
+			t.Fatalf("CreateToken() error = nil")
+		}
+
+		if entity, _ := j.persistence.Find(oldest.RefreshToken.ID); entity.Status != TokenEnabled {
+			t.Errorf("CreateToken() oldest session status = %v, want %v", entity.Status, TokenEnabled)
+		}
+	})
+
+	t.Run("limits the sessions per device class", func(t *testing.T) {
+		j, clock := newProvider(SessionLimitPolicy{
+			MaxSessions:               3,
+			MaxSessionsPerDeviceClass: map[string]int{"mobile": 1},
+			EvictOldest:               true,
+		})
+
+		web, err := login(j, clock, "web")
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+		phone, err := login(j, clock, "mobile")
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+		if _, err = login(j, clock, "mobile"); err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		if entity, _ := j.persistence.Find(phone.RefreshToken.ID); entity.Status != TokenRevoked {
+			t.Errorf("CreateToken() previous mobile session status = %v, want %v", entity.Status, TokenRevoked)
+		}
+		if entity, _ := j.persistence.Find(web.RefreshToken.ID); entity.Status != TokenEnabled {
+			t.Errorf("CreateToken() web session status = %v, want %v", entity.Status, TokenEnabled)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package authentication_pool
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// IsPurgeable tells if the token cannot be used anymore since the given date. The revoked tokens are purgeable since
+// they were revoked, the other tokens since they expired. The refresh tokens without expiration use the limit of their
+// session, if they have none they are never purgeable while enabled.
+func IsPurgeable(entity *Entity, before time.Time) bool {
+	if entity.Status == TokenRevoked {
+		return entity.UpdatedAt.Before(before)
+	}
+
+	expiredAt := entity.ExpiredAt
+	if expiredAt == nil {
+		expiredAt = entity.SessionExpiredAt
+	}
+
+	return expiredAt != nil && expiredAt.Before(before)
+}
+
+// TokenSweeper removes from the persistence the tokens that cannot be used anymore.
+type TokenSweeper struct {
+	persistence  TokenPersistence
+	interval     time.Duration
+	retention    time.Duration
+	timeProvider timeProvider
+	onSweep      func(removed int, err error)
+}
+
+type TokenSweeperOptions func(s *TokenSweeper) error
+
+// OnSweep calls the given function after every sweep made by Start with the number of removed tokens.
+func OnSweep(callback func(removed int, err error)) TokenSweeperOptions {
+	return func(s *TokenSweeper) error {
+		if callback == nil {
+			return errors.New("the sweep callback cannot be nil")
+		}
+
+		s.onSweep = callback
+		return nil
+	}
+}
+
+// NewTokenSweeper creates a sweeper that runs every interval. The tokens are kept during the retention after they
+// expire or are revoked, so the reuse of a token can still be told apart from an unknown token for a while.
+func NewTokenSweeper(persistence TokenPersistence, interval, retention time.Duration, opts ...TokenSweeperOptions) (*TokenSweeper, error) {
+	if interval <= 0 {
+		return nil, errors.New("the sweep interval must be greater than 0")
+	}
+
+	if retention < 0 {
+		return nil, errors.New("the retention cannot be negative")
+	}
+
+	sweeper := &TokenSweeper{
+		persistence:  persistence,
+		interval:     interval,
+		retention:    retention,
+		timeProvider: osTimeProvider,
+		onSweep:      func(int, error) {},
+	}
+
+	for _, opt := range opts {
+		if err := opt(sweeper); err != nil {
+			return nil, err
+		}
+	}
+
+	return sweeper, nil
+}
+
+// Sweep removes the purgeable tokens once and returns how many were removed. The tokens of the same family are removed
+// together once all of them are purgeable: the expired access tokens link the rotated refresh tokens with the live one,
+// the reuse detection and the refresh without access token need them while the session is alive. The rotated refresh
+// tokens only matter while the family has a usable token, so they do not keep a family that was logged out or that
+// expired. The tokens removed by someone else in the meantime are not counted.
+func (s *TokenSweeper) Sweep() (int, error) {
+	before := s.timeProvider().Add(-s.retention)
+	entities, err := s.persistence.ListPurgeable(before)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	checked := map[string]bool{}
+	for _, entity := range entities {
+		if checked[entity.ID] {
+			continue
+		}
+
+		family, err := tokenFamily(s.persistence, entity)
+		if err != nil {
+			return removed, err
+		}
+
+		purgeable := true
+		for _, member := range family {
+			checked[member.ID] = true
+			purgeable = purgeable && (member.Status == TokenRotated || IsPurgeable(member, before))
+		}
+
+		if !purgeable {
+			continue
+		}
+
+		for _, member := range family {
+			err := s.persistence.Delete(member.ID)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return removed, err
+			}
+
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// Start sweeps in background every interval until the returned function is called. The stop function waits for the
+// running sweep to finish, it can be called more than once.
+func (s *TokenSweeper) Start() (stop func()) {
+	ticker := time.NewTicker(s.interval)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ticker.C:
+				s.onSweep(s.Sweep())
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(quit)
+		})
+		<-done
+	}
+}
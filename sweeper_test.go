@@ -0,0 +1,207 @@
+package authentication_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsPurgeable(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		entity *Entity
+		want   bool
+	}{
+		{name: "expired token", entity: &Entity{Status: TokenEnabled, ExpiredAt: &past}, want: true},
+		{name: "valid token", entity: &Entity{Status: TokenEnabled, ExpiredAt: &future}},
+		{name: "revoked token", entity: &Entity{Status: TokenRevoked, ExpiredAt: &future, UpdatedAt: past}, want: true},
+		{name: "recently revoked token", entity: &Entity{Status: TokenRevoked, UpdatedAt: future}},
+		{name: "rotated token before it expires", entity: &Entity{Status: TokenRotated, ExpiredAt: &future, UpdatedAt: past}},
+		{name: "refresh token whose session expired", entity: &Entity{Status: TokenRotated, SessionExpiredAt: &past}, want: true},
+		{name: "refresh token without expiration", entity: &Entity{Status: TokenEnabled, Type: RefreshTokenType}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPurgeable(tt.entity, now); got != tt.want {
+				t.Errorf("IsPurgeable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSweeper_Sweep(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	expired, valid := now.Add(-time.Hour*2), now.Add(time.Hour)
+
+	persistence := NewInMemoryTokenPersistence()
+	entities := []*Entity{
+		{ID: "expired", Status: TokenEnabled, ExpiredAt: &expired},
+		{ID: "valid", Status: TokenEnabled, ExpiredAt: &valid},
+		{ID: "revoked", Status: TokenRevoked, ExpiredAt: &valid, UpdatedAt: now.Add(-time.Minute)},
+		{ID: "revoked-long-ago", Status: TokenRevoked, ExpiredAt: &valid, UpdatedAt: expired},
+	}
+	for _, entity := range entities {
+		if err := persistence.Save(entity); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sweeper, err := NewTokenSweeper(persistence, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewTokenSweeper() error = %v", err)
+	}
+	sweeper.timeProvider = newFixedTimeProvider(now).Now
+
+	removed, err := sweeper.Sweep()
+	if err != nil || removed != 2 {
+		t.Fatalf("Sweep() got = %v, error = %v, want 2", removed, err)
+	}
+
+	for id, want := range map[string]bool{"expired": false, "valid": true, "revoked": true, "revoked-long-ago": false} {
+		if _, err := persistence.Find(id); (err == nil) != want {
+			t.Errorf("Find(%v) error = %v, want stored %v", id, err, want)
+		}
+	}
+
+	if removed, err = sweeper.Sweep(); err != nil || removed != 0 {
+		t.Errorf("Sweep() again got = %v, error = %v, want 0", removed, err)
+	}
+}
+
+func TestTokenSweeper_Start(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	persistence := NewInMemoryTokenPersistence()
+	_ = persistence.Save(&Entity{ID: "expired", Status: TokenEnabled, ExpiredAt: &expired})
+
+	sweeps := make(chan int, 100)
+	sweeper, err := NewTokenSweeper(persistence, time.Millisecond, 0, OnSweep(func(removed int, err error) {
+		if err == nil {
+			sweeps <- removed
+		}
+	}))
+	if err != nil {
+		t.Fatalf("NewTokenSweeper() error = %v", err)
+	}
+
+	stop := sweeper.Start()
+	select {
+	case removed := <-sweeps:
+		if removed != 1 {
+			t.Errorf("OnSweep() got = %v, want 1", removed)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the sweeper did not run")
+	}
+
+	stop()
+	stop()
+
+	if _, err = persistence.Find("expired"); err != ErrNotFound {
+		t.Errorf("Find() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestNewTokenSweeper(t *testing.T) {
+	if _, err := NewTokenSweeper(NewInMemoryTokenPersistence(), 0, time.Hour); err == nil {
+		t.Errorf("NewTokenSweeper() without interval error = nil")
+	}
+
+	if _, err := NewTokenSweeper(NewInMemoryTokenPersistence(), time.Minute, -time.Hour); err == nil {
+		t.Errorf("NewTokenSweeper() with negative retention error = nil")
+	}
+}
+
+func TestTokenSweeper_SweepFamilies(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	newProvider := func() (*JWTTokenProvider, *fixedTimeProvider, *TokenSweeper) {
+		clock := newFixedTimeProvider(issuedAt)
+		j := newTestJWTTokenProvider(issuedAt, issuedAt)
+		j.timeProvider = clock.Now
+		j.jwtHandler.(*PascalDeKloeJWTHandler).timeProvider = clock.Now
+		j.refreshTokenOnly = true
+
+		sweeper, err := NewTokenSweeper(j.persistence, time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sweeper.timeProvider = clock.Now
+
+		return j, clock, sweeper
+	}
+
+	t.Run("keeps the expired access tokens of a live session", func(t *testing.T) {
+		j, clock, sweeper := newProvider()
+		first, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.now = issuedAt.Add(time.Hour)
+		second, err := j.Refresh(&RefreshTokenInput{RefreshToken: first.RefreshToken.Token})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.now = issuedAt.Add(time.Hour * 3)
+		if removed, err := sweeper.Sweep(); err != nil || removed != 0 {
+			t.Fatalf("Sweep() got = %v, error = %v, want 0", removed, err)
+		}
+
+		third, err := j.Refresh(&RefreshTokenInput{RefreshToken: second.RefreshToken.Token})
+		if err != nil {
+			t.Fatalf("Refresh() with the refresh token only error = %v", err)
+		}
+
+		if _, err = j.Refresh(&RefreshTokenInput{RefreshToken: first.RefreshToken.Token}); err != ErrReusedToken {
+			t.Errorf("Refresh() with a rotated token error = %v, want %v", err, ErrReusedToken)
+		}
+
+		if _, err = j.Refresh(&RefreshTokenInput{RefreshToken: third.RefreshToken.Token}); err != ErrDisabledToken {
+			t.Errorf("Refresh() after the reuse error = %v, want %v", err, ErrDisabledToken)
+		}
+	})
+
+	t.Run("removes the family after the logout", func(t *testing.T) {
+		j, clock, sweeper := newProvider()
+		first, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.now = issuedAt.Add(time.Hour)
+		second, err := j.Refresh(&RefreshTokenInput{RefreshToken: first.RefreshToken.Token})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = j.Logout(second.AccessToken.Content); err != nil {
+			t.Fatal(err)
+		}
+
+		clock.now = issuedAt.Add(time.Hour * 3)
+		if removed, err := sweeper.Sweep(); err != nil || removed != 4 {
+			t.Errorf("Sweep() got = %v, error = %v, want 4", removed, err)
+		}
+	})
+
+	t.Run("removes the family once the session expired", func(t *testing.T) {
+		j, clock, sweeper := newProvider()
+		j.absoluteTimeout = time.Hour * 2
+		first, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.now = issuedAt.Add(time.Hour)
+		if _, err = j.Refresh(&RefreshTokenInput{RefreshToken: first.RefreshToken.Token}); err != nil {
+			t.Fatal(err)
+		}
+
+		clock.now = issuedAt.Add(time.Hour * 4)
+		if removed, err := sweeper.Sweep(); err != nil || removed != 4 {
+			t.Errorf("Sweep() got = %v, error = %v, want 4", removed, err)
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package authentication_pool
 
+var _ SynchronizationChecker = &LocalSynchronization{}
+
 type LocalSynchronization struct {
 	localCustomerRegister    LocalCustomerRegister
 	federatedAccountRegister FederatedAccountRegister
@@ -12,6 +14,9 @@ func NewLocalSynchronization(customerRegister LocalCustomerRegister, accountRegi
 	}
 }
 
+// Synchronize links the federated account to the local account with the same email. The emails that the provider has
+// not verified are not linked to the existing accounts, otherwise anyone could take an account by using its email in
+// another provider.
 func (l LocalSynchronization) Synchronize(input *SynchronizeInput) (*SynchronizeOutput, error) {
 	output, err := l.initializeLocalAccount(input)
 	if err != nil {
@@ -23,20 +28,42 @@ func (l LocalSynchronization) Synchronize(input *SynchronizeInput) (*Synchronize
 		return nil, err
 	}
 
+	// Some providers only give the name on the first login, then the name saved in the federated account is used.
+	firstName, lastName := input.FirstName, input.LastName
+	if firstName == "" && lastName == "" {
+		firstName, lastName = federatedOutput.FirstName, federatedOutput.LastName
+	}
+
 	return &SynchronizeOutput{
 		NewUser:             output.NewUser,
 		NewAccount:          federatedOutput.NewUser,
 		CustomerID:          output.CustomerID,
 		ReferenceInProvider: input.ID,
-		FirstName:           input.FirstName,
-		LastName:            input.LastName,
+		FirstName:           firstName,
+		LastName:            lastName,
 		Email:               input.Email,
 		PhotoURL:            input.PhotoURL,
 	}, nil
 }
 
+// CheckSynchronization tells beforehand if Synchronize would reject the account because of its unverified email.
+func (l LocalSynchronization) CheckSynchronization(input *SynchronizeInput) error {
+	customer, err := l.localCustomerRegister.Find(&FindLocalAccountInput{Email: input.Email})
+	if err != nil {
+		return err
+	}
+
+	if customer == nil {
+		return nil
+	}
+
+	return l.checkUnverifiedEmail(customer.ID, input)
+}
+
 type initializeFederatedAccountOutput struct {
-	NewUser bool
+	NewUser   bool
+	FirstName string
+	LastName  string
 }
 
 func (l LocalSynchronization) initializeFederatedAccount(customerID string, validationResult *SynchronizeInput) (*initializeFederatedAccountOutput, error) {
@@ -45,8 +72,12 @@ func (l LocalSynchronization) initializeFederatedAccount(customerID string, vali
 		UserID:   customerID,
 	})
 
-	if err != nil || account != nil {
-		return &initializeFederatedAccountOutput{NewUser: false}, err
+	if err != nil {
+		return nil, err
+	}
+
+	if account != nil {
+		return &initializeFederatedAccountOutput{NewUser: false, FirstName: account.FirstName, LastName: account.LastName}, nil
 	}
 
 	_, err = l.federatedAccountRegister.Create(&CreateFederatedAccountInput{
@@ -68,6 +99,10 @@ func (l LocalSynchronization) initializeLocalAccount(validationResult *Synchroni
 	}
 
 	if customer != nil {
+		if err = l.checkUnverifiedEmail(customer.ID, validationResult); err != nil {
+			return nil, err
+		}
+
 		return &initializeLocalAccountOutput{
 			CustomerID: customer.ID,
 			NewUser:    false,
@@ -85,6 +120,38 @@ func (l LocalSynchronization) initializeLocalAccount(validationResult *Synchroni
 	}, nil
 }
 
+// checkUnverifiedEmail rejects the emails that the provider has not verified when they belong to a customer that is
+// not linked to the account of the provider yet.
+func (l LocalSynchronization) checkUnverifiedEmail(customerID string, validationResult *SynchronizeInput) error {
+	if validationResult.EmailVerified {
+		return nil
+	}
+
+	linked, err := l.isLinked(customerID, validationResult)
+	if err != nil {
+		return err
+	}
+
+	if !linked {
+		return NewValidationInputFailed("the email has not been verified by the provider")
+	}
+
+	return nil
+}
+
+// isLinked tells if the account of the provider has been linked to the customer already.
+func (l LocalSynchronization) isLinked(customerID string, validationResult *SynchronizeInput) (bool, error) {
+	account, err := l.federatedAccountRegister.Find(&FindFederatedAccountInput{
+		Provider: validationResult.Provider,
+		UserID:   customerID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return account != nil && account.ReferenceInProvider == validationResult.ID, nil
+}
+
 type initializeLocalAccountOutput struct {
 	CustomerID string
 	NewUser    bool
@@ -39,3 +39,76 @@ func TestLocalSynchronization_Synchronize(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalSynchronization_SynchronizeKeepsTheName(t *testing.T) {
+	l := NewLocalSynchronization(NewInMemoryCustomerRepository(UUIDGenerator), NewInMemoryFederatedAccountRepository())
+
+	first, err := l.Synchronize(&SynchronizeInput{Provider: "apple", ID: "001", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The name is only given on the first login.
+	got, err := l.Synchronize(&SynchronizeInput{Provider: "apple", ID: "001", Email: "jane@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("Synchronize() error = %v", err)
+	}
+
+	if got.CustomerID != first.CustomerID || got.NewAccount || got.FirstName != "Jane" || got.LastName != "Doe" {
+		t.Errorf("Synchronize() got = %v", got)
+	}
+}
+
+func TestLocalSynchronization_SynchronizeUnverifiedEmails(t *testing.T) {
+	l := NewLocalSynchronization(NewInMemoryCustomerRepository(UUIDGenerator), NewInMemoryFederatedAccountRepository())
+
+	if _, err := l.Synchronize(&SynchronizeInput{Provider: "local", ID: "001", Email: "jane@example.com", EmailVerified: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := l.Synchronize(&SynchronizeInput{Provider: "google", ID: "002", Email: "jane@example.com"})
+	if _, ok := err.(*ValidationInputFailed); !ok {
+		t.Errorf("Synchronize() to an existing account error = %v, want a validation error", err)
+	}
+
+	first, err := l.Synchronize(&SynchronizeInput{Provider: "google", ID: "003", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Synchronize() to a new account error = %v", err)
+	}
+
+	got, err := l.Synchronize(&SynchronizeInput{Provider: "google", ID: "003", Email: "john@example.com"})
+	if err != nil || got.CustomerID != first.CustomerID {
+		t.Errorf("Synchronize() of a linked account got = %v, error = %v", got, err)
+	}
+}
+
+func TestLocalProvider_SignUpUnverifiedEmailOfAnotherProvider(t *testing.T) {
+	l := NewLocalSynchronization(NewInMemoryCustomerRepository(UUIDGenerator), NewInMemoryFederatedAccountRepository())
+	if _, err := l.Synchronize(&SynchronizeInput{Provider: "google", ID: "001", Email: "jane@example.com", EmailVerified: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	api := NewInMemoryLocalAPI(UUIDGenerator)
+	signedUp := 0
+	provider, err := NewLocalProvider(api, l, AfterSignUp([]OnSignUp{func(*SignUpOutput) { signedUp++ }}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = provider.SignUp(&SignUpInput{Email: "jane@example.com", Secret: "Secret#1234", Validated: false})
+	if _, ok := err.(*ValidationInputFailed); !ok {
+		t.Fatalf("SignUp() error = %v, want a validation error", err)
+	}
+
+	if user, err := api.User("jane@example.com"); err != nil || user != nil {
+		t.Errorf("User() got = %v, error = %v, want the user not registered", user, err)
+	}
+
+	if _, err = provider.SignUp(&SignUpInput{Email: "jane@example.com", Secret: "Secret#1234", Validated: true}); err != nil {
+		t.Errorf("SignUp() with a validated email error = %v", err)
+	}
+
+	if signedUp != 1 {
+		t.Errorf("SignUp() callbacks = %d, want 1", signedUp)
+	}
+}
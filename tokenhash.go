@@ -0,0 +1,86 @@
+package authentication_pool
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var _ TokenHasher = &HMACTokenHasher{}
+
+// TokenHasher protects the token secrets saved in the persistence, so a copy of the storage cannot be used to create
+// sessions.
+type TokenHasher interface {
+	// Make returns the value saved in the persistence for the given secret.
+	Make(secret string) (string, error)
+	// Compare tells if the given secret matches the saved value. The comparison takes constant time.
+	Compare(secret string, stored string) (bool, error)
+	// NeedsRehash tells if the saved value must be made again, because it is plain text or it was made with an old key.
+	NeedsRehash(stored string) bool
+}
+
+const hmacTokenHashPrefix = "$hmac-sha256$"
+
+// HMACTokenHasher hashes the secrets with HMAC-SHA256. The saved values carry the version of the key, so the key can be
+// rotated: the new secrets use the current key and the previous keys keep verifying the saved values.
+type HMACTokenHasher struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewHMACTokenHasher creates a hasher that makes the values with the current key version. The keys must have at least
+// 32 bytes and the versions cannot contain "$".
+func NewHMACTokenHasher(current string, keys map[string][]byte) (*HMACTokenHasher, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("the key version %q does not exist", current)
+	}
+
+	for version, key := range keys {
+		if version == "" || strings.Contains(version, "$") {
+			return nil, fmt.Errorf("the key version %q is not valid", version)
+		}
+		if len(key) < 32 {
+			return nil, errors.New("the HMAC keys must have at least 32 bytes")
+		}
+	}
+
+	return &HMACTokenHasher{current: current, keys: keys}, nil
+}
+
+func (h HMACTokenHasher) Make(secret string) (string, error) {
+	return hmacTokenHashPrefix + h.current + "$" + h.sum(h.keys[h.current], secret), nil
+}
+
+// Compare accepts values made with any of the known keys. The values without the hash prefix were saved before the
+// hasher was configured, they are compared as plain text.
+func (h HMACTokenHasher) Compare(secret string, stored string) (bool, error) {
+	if !strings.HasPrefix(stored, hmacTokenHashPrefix) {
+		return subtle.ConstantTimeCompare([]byte(secret), []byte(stored)) == 1, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(stored, hmacTokenHashPrefix), "$", 2)
+	if len(parts) != 2 {
+		return false, nil
+	}
+
+	key, ok := h.keys[parts[0]]
+	if !ok {
+		return false, nil
+	}
+
+	return hmac.Equal([]byte(h.sum(key, secret)), []byte(parts[1])), nil
+}
+
+func (h HMACTokenHasher) NeedsRehash(stored string) bool {
+	return !strings.HasPrefix(stored, hmacTokenHashPrefix+h.current+"$")
+}
+
+func (h HMACTokenHasher) sum(key []byte, secret string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(secret))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,113 @@
+package authentication_pool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACTokenHasher(t *testing.T) {
+	previous, err := NewHMACTokenHasher("v1", map[string][]byte{"v1": bytes.Repeat([]byte("a"), 32)})
+	if err != nil {
+		t.Fatalf("NewHMACTokenHasher() error = %v", err)
+	}
+
+	current, err := NewHMACTokenHasher("v2", map[string][]byte{
+		"v1": bytes.Repeat([]byte("a"), 32),
+		"v2": bytes.Repeat([]byte("b"), 32),
+	})
+	if err != nil {
+		t.Fatalf("NewHMACTokenHasher() error = %v", err)
+	}
+
+	old, _ := previous.Make("secret")
+	stored, _ := current.Make("secret")
+	if !strings.HasPrefix(stored, "$hmac-sha256$v2$") || strings.Contains(stored, "secret") {
+		t.Errorf("Make() got = %v", stored)
+	}
+
+	tests := []struct {
+		name        string
+		secret      string
+		stored      string
+		want        bool
+		needsRehash bool
+	}{
+		{name: "current key", secret: "secret", stored: stored, want: true, needsRehash: false},
+		{name: "previous key", secret: "secret", stored: old, want: true, needsRehash: true},
+		{name: "plain text", secret: "secret", stored: "secret", want: true, needsRehash: true},
+		{name: "another secret", secret: "other", stored: stored, want: false, needsRehash: false},
+		{name: "unknown key", secret: "secret", stored: "$hmac-sha256$v0$" + stored[len("$hmac-sha256$v2$"):], want: false, needsRehash: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := current.Compare(tt.secret, tt.stored)
+			if err != nil || got != tt.want {
+				t.Errorf("Compare() got = %v, %v, want %v", got, err, tt.want)
+			}
+
+			if got := current.NeedsRehash(tt.stored); got != tt.needsRehash {
+				t.Errorf("NeedsRehash() got = %v, want %v", got, tt.needsRehash)
+			}
+		})
+	}
+
+	if _, err = NewHMACTokenHasher("v1", map[string][]byte{"v1": []byte("short")}); err == nil {
+		t.Errorf("NewHMACTokenHasher() with a short key error = nil")
+	}
+}
+
+func TestJWTTokenProvider_HashTokenSecrets(t *testing.T) {
+	issuedAt := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	hasher, err := NewHMACTokenHasher("v1", map[string][]byte{"v1": bytes.Repeat([]byte("k"), 32)})
+	if err != nil {
+		t.Fatalf("NewHMACTokenHasher() error = %v", err)
+	}
+
+	t.Run("saves the secrets hashed", func(t *testing.T) {
+		j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Hour))
+		j.tokenHasher = hasher
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		entity, _ := j.persistence.Find(tokens.RefreshToken.ID)
+		if entity.Content == tokens.RefreshToken.Content || hasher.NeedsRehash(entity.Content) {
+			t.Errorf("CreateToken() saved content = %v", entity.Content)
+		}
+
+		if _, err = j.Refresh(&RefreshTokenInput{
+			RefreshToken: tokens.RefreshToken.Token,
+			AccessToken:  tokens.AccessToken.Content,
+		}); err != nil {
+			t.Errorf("Refresh() error = %v", err)
+		}
+	})
+
+	t.Run("hashes the saved plain text secrets when they are used", func(t *testing.T) {
+		j := newTestJWTTokenProvider(issuedAt, issuedAt.Add(time.Hour))
+		tokens, err := j.CreateToken(&CreateTokenInput{ID: "customer"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		j.tokenHasher = hasher
+		if _, err = j.findObscureToken(tokens.RefreshToken.Token); err != nil {
+			t.Fatalf("findObscureToken() error = %v", err)
+		}
+
+		entity, _ := j.persistence.Find(tokens.RefreshToken.ID)
+		if hasher.NeedsRehash(entity.Content) {
+			t.Errorf("findObscureToken() saved content = %v, want it hashed", entity.Content)
+		}
+
+		if _, err = j.Refresh(&RefreshTokenInput{
+			RefreshToken: tokens.RefreshToken.Token,
+			AccessToken:  tokens.AccessToken.Content,
+		}); err != nil {
+			t.Errorf("Refresh() error = %v", err)
+		}
+	})
+}
@@ -11,6 +11,28 @@ var ErrExpiredToken = errors.New("the given Token has expired")
 
 var ErrDisabledToken = errors.New("the given token has been revoked")
 
+var ErrReusedToken = errors.New("the given refresh token has been used already")
+
+var ErrInvalidIssuer = errors.New("the given token has been issued by another issuer")
+
+var ErrInvalidAudience = errors.New("the given token has been issued for another audience")
+
+var ErrTokenNotValidYet = errors.New("the given token is not valid yet")
+
+var ErrTokenIssuedInFuture = errors.New("the given token has been issued in the future")
+
+var ErrInvalidScope = errors.New("the requested scope has not been granted to the given token")
+
+var ErrDPoPProofRequired = errors.New("the given token can only be used with a DPoP proof")
+
+var ErrInvalidDPoPProof = errors.New("the given DPoP proof is not valid")
+
+var ErrTokenNotExpired = errors.New("the given access token has not expired")
+
+var ErrSessionLimitReached = errors.New("the maximum number of sessions has been reached")
+
+var ErrStatusChanged = errors.New("the given token has another status")
+
 var ErrDuplicatedEntityExists = errors.New("the given User already exists")
 
 var ErrNotFound = errors.New("the given User does not exists")
@@ -26,6 +48,19 @@ type AuthenticationHandler interface {
 type AuthenticateInput struct {
 	Email  string
 	Secret string
+	// Nonce is the value sent in the authentication request to an OpenID Connect provider, the id_token must have it.
+	Nonce string
+	// FirstName and LastName are sent by the clients when the provider does not put the name in its tokens, like Apple
+	// that only gives it on the first authorization.
+	FirstName string
+	LastName  string
+	// UserAgent and IPAddress describe the device that is authenticating, they are saved in the session.
+	UserAgent string
+	IPAddress string
+	// DeviceClass groups the devices for the session limits, like "mobile" or "web".
+	DeviceClass string
+	// DPoPThumbprint is the JWK thumbprint of the client key, the access token is bound to it.
+	DPoPThumbprint string
 }
 
 type AuthenticateOutput struct {
@@ -38,6 +73,7 @@ type AuthenticateOutput struct {
 
 type AuthenticationVerifyOutput struct {
 	Account *LocalAccount
+	Claims  *VerifyOutput
 }
 
 type AccountRetriever interface {
@@ -46,14 +82,19 @@ type AccountRetriever interface {
 }
 
 type InitializeAccountInput struct {
-	Email  string
-	Secret string
+	Email     string
+	Secret    string
+	Nonce     string
+	FirstName string
+	LastName  string
 }
 
 type InitializeAccountOutput struct {
 	Customer   *CustomerAccount
 	NewUser    bool
 	NewAccount bool
+	// Provider is the name of the provider that validated the credentials.
+	Provider string
 }
 
 type CustomerAccount struct {
@@ -64,6 +105,10 @@ type CustomerAccount struct {
 	FirstName     string
 	LastName      string
 	PhotoURL      *string
+	// PrivateEmail tells that the email is a relay address, messages sent to it are forwarded by the provider.
+	PrivateEmail bool
+	// Username is the handle of the user in the provider, not all the providers have it.
+	Username string
 }
 
 type UpdatePasswordInput struct {
@@ -81,13 +126,23 @@ type AccountSynchronization interface {
 	Synchronize(input *SynchronizeInput) (*SynchronizeOutput, error)
 }
 
+// SynchronizationChecker is implemented by the synchronizations that can reject an account beforehand, so the
+// providers with store do not register the users whose account cannot be synchronized.
+type SynchronizationChecker interface {
+	// CheckSynchronization returns the validation error that Synchronize would return for the given input, without
+	// changing anything.
+	CheckSynchronization(input *SynchronizeInput) error
+}
+
 type SynchronizeInput struct {
 	Provider  string
 	ID        string
 	FirstName string
 	LastName  string
 	Email     string
-	PhotoURL  *string
+	// EmailVerified tells that the provider has verified that the email belongs to the user.
+	EmailVerified bool
+	PhotoURL      *string
 }
 
 type SynchronizeOutput struct {
@@ -112,6 +167,12 @@ type Provider interface {
 type ValidationInput struct {
 	Email  string
 	Secret string
+	// Nonce is only used by the providers that validate id_tokens.
+	Nonce string
+	// FirstName and LastName are the name given by the client, they are only used by the providers whose tokens have
+	// no name.
+	FirstName string
+	LastName  string
 }
 
 func NewValidationInput(email string, secret string) *ValidationInput {
@@ -125,10 +186,21 @@ type ValidationOutput struct {
 	Email          string
 	PhotoURL       *string
 	EmailValidated bool
+	// PrivateEmail tells that the email is a relay address created by the provider for the application.
+	PrivateEmail bool
+	// Username is the handle of the user in the provider, like the GitHub login.
+	Username string
 }
 
 func NewValidationOutput(ID, firstName, lastName, email string, photo *string, validated bool) *ValidationOutput {
-	return &ValidationOutput{ID, firstName, lastName, email, photo, validated}
+	return &ValidationOutput{
+		ID:             ID,
+		FirstName:      firstName,
+		LastName:       lastName,
+		Email:          email,
+		PhotoURL:       photo,
+		EmailValidated: validated,
+	}
 }
 
 type ProviderWithStore interface {
@@ -201,6 +273,8 @@ type LocalCustomerRegister interface {
 	Create(input *CreateLocalAccountInput) (*LocalAccount, error)
 	// Find retrieve the account by email, if there are no valid accounts return nil, nil.
 	Find(input *FindLocalAccountInput) (*LocalAccount, error)
+	// FindByID retrieve the account by its ID, if there are no valid accounts return nil, nil.
+	FindByID(input *FindLocalAccountByIDInput) (*LocalAccount, error)
 	Delete(input *DeleteLocalAccountInput) (*LocalAccount, error)
 	Enable(input *EnableLocalAccountInput) (*LocalAccount, error)
 	Disable(input *DisableLocalAccountInput) (*LocalAccount, error)
@@ -223,6 +297,10 @@ type FindLocalAccountInput struct {
 	Email string
 }
 
+type FindLocalAccountByIDInput struct {
+	ID string
+}
+
 type DeleteLocalAccountInput struct {
 	Email string
 }
@@ -274,15 +352,27 @@ type CreateFederatedAccountOutput struct {
 type TokenProvider interface {
 	// CreateToken retrieves a new Token based in the User properties.
 	CreateToken(input *CreateTokenInput) (*CreateTokenOutput, error)
-	// Refresh takes a refresh Token, the refreshed token and creates a new one if its valid.
+	// Refresh takes a refresh Token, the refreshed token and creates a new one if its valid. The given refresh Token is
+	// retired and a new one is returned, if a retired refresh Token is used again the whole family of tokens is revoked.
 	Refresh(input *RefreshTokenInput) (*RefreshTokenOutput, error)
-	// Verify takes a token and validate it. Does not read the status property from the storage.
+	// Verify takes a token and validate it. Does not read the status property from the storage unless the provider
+	// has been configured to do it.
 	Verify(input string) (*VerifyTokenOutput, error)
+	// Revoke disables the token with the given ID.
+	Revoke(tokenID string) error
+	// RevokeAllForUser disables every token issued to the given user.
+	RevokeAllForUser(userID string) error
+	// Logout takes an access token and disables every token issued from the same authentication, the exchanged tokens too.
+	Logout(accessToken string) error
 }
 
 type VerifyTokenOutput struct {
-	Valid         bool
+	Valid bool
+	// Subject is the ID of the customer the token was issued to.
+	Subject string
+	// Deprecated: the email can change, use Subject to identify the customer.
 	CustomerEmail *string
+	Claims        *VerifyOutput
 }
 
 type CreateTokenInput struct {
@@ -293,10 +383,24 @@ type CreateTokenInput struct {
 	Email         string
 	EmailVerified bool
 	Picture       *string
+	// Scope is the space separated list of scopes granted to the token.
+	Scope string
+	// ClientID is the client that requested the token.
+	ClientID string
+	// UserAgent, IPAddress and Provider describe the authentication, they are saved in the session.
+	UserAgent string
+	IPAddress string
+	Provider  string
+	// DeviceClass groups the devices for the session limits, like "mobile" or "web".
+	DeviceClass string
+	// DPoPThumbprint is the JWK thumbprint of the client key, the access tokens can only be used along with a DPoP proof
+	// signed by that key.
+	DPoPThumbprint string
 }
 
 type RefreshTokenOutput struct {
-	AccessToken *Token
+	AccessToken  *Token
+	RefreshToken *RefreshToken
 }
 
 type CreateTokenOutput struct {
@@ -316,19 +420,76 @@ type RefreshToken struct {
 	ID      string
 	Content string
 	Token   string
+	// ExpireAt is nil when the provider has no session lifetime.
+	ExpireAt *time.Time
 }
 
 type RefreshTokenInput struct {
 	RefreshToken string
-	AccessToken  string
+	// AccessToken is the token issued along with the refresh token. It can be empty when the provider allows to refresh
+	// with the refresh token only.
+	AccessToken string
 }
 
 type TokenPersistence interface {
 	Save(entity *Entity) error
 	// Find retrieves a token by its ID. If the given token is not available returns nil, and ErrNotFound.
 	Find(tokenID string) (*Entity, error)
+	// Update replaces the stored token with the given entity. If the given token does not exist returns ErrNotFound.
+	Update(entity *Entity) error
+	// UpdateStatus changes the status of the given token only when it has the status from, the check and the change
+	// must be atomic. If the token has another status returns ErrStatusChanged, if it does not exist returns
+	// ErrNotFound.
+	UpdateStatus(tokenID, from, to string, updatedAt time.Time) error
+	// FindRelated retrieves the tokens whose RelatedTokenID is the given token ID. If there are no tokens returns an
+	// empty slice.
+	FindRelated(tokenID string) ([]*Entity, error)
+	// FindByUser retrieves the tokens issued to the given user. If there are no tokens returns an empty slice.
+	FindByUser(userID string) ([]*Entity, error)
+	// FindSessions retrieves the enabled refresh tokens of the given user, there is one per session. If there are no
+	// tokens returns an empty slice.
+	FindSessions(userID string) ([]*Entity, error)
+	// ListPurgeable retrieves the tokens that cannot be used anymore since the given date: the tokens that expired and
+	// the revoked tokens. The rotated refresh tokens are kept until they expire, they detect the reuse of the tokens. If
+	// there are no tokens returns an empty slice.
+	ListPurgeable(before time.Time) ([]*Entity, error)
+	// Delete removes the given token. If the given token does not exist returns ErrNotFound.
+	Delete(tokenID string) error
+}
+
+type SessionManager interface {
+	// Sessions returns the sessions of the given user that can be refreshed, the most recently used first.
+	Sessions(userID string) ([]*Session, error)
+	// RevokeSession disables every token of the given session. If the session does not belong to the user returns
+	// ErrNotFound.
+	RevokeSession(userID, sessionID string) error
+}
+
+// Session is the authentication of a user in a device. It is saved along with the refresh token and it is kept when the
+// refresh token is rotated.
+type Session struct {
+	ID          string
+	UserAgent   string
+	IPAddress   string
+	Provider    string
+	DeviceClass string
+	CreatedAt   time.Time
+	LastUsedAt  time.Time
 }
 
+// RefreshTokenType is the Entity type of the refresh tokens.
+const RefreshTokenType = "refresh"
+
+const (
+	// TokenEnabled is the status of a token that can be used.
+	TokenEnabled = "enabled"
+	// TokenRotated is the status of a refresh token that has been exchanged for a new one. Presenting it again means
+	// that it has been leaked.
+	TokenRotated = "rotated"
+	// TokenRevoked is the status of a token that cannot be used anymore.
+	TokenRevoked = "revoked"
+)
+
 type Entity struct {
 	ID             string
 	Type           string
@@ -336,9 +497,17 @@ type Entity struct {
 	UserID         string
 	Content        string
 	RelatedTokenID *string
+	Scope          string
+	ClientID       string
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	ExpiredAt      *time.Time
+	// SessionExpiredAt is the absolute limit of the session of a refresh token, it is kept when the token is rotated.
+	SessionExpiredAt *time.Time
+	// Session is only set in the refresh tokens.
+	Session *Session
+	// DPoPThumbprint is the key the access token is bound to.
+	DPoPThumbprint string
 }
 
 func NewEntity(ID, tokenType, userID, content string, relatedTokenID *string, expiredAt *time.Time) *Entity {
@@ -348,7 +517,7 @@ func NewEntity(ID, tokenType, userID, content string, relatedTokenID *string, ex
 		UserID:         userID,
 		Content:        content,
 		ExpiredAt:      expiredAt,
-		Status:         "enabled",
+		Status:         TokenEnabled,
 		RelatedTokenID: relatedTokenID,
 		CreatedAt:      time.Now(),
 	}
@@ -363,6 +532,8 @@ type IssueInput struct {
 	RegisteredClaims RegisteredClaims
 	PublicClaims     PublicClaims
 	PrivateClaims    PrivateClaims
+	// TimeToLive overrides the time to live of the handler when it is not zero.
+	TimeToLive time.Duration
 }
 
 type IssueOutput struct {
@@ -375,6 +546,23 @@ type RegisteredClaims struct {
 	Subject        string
 	Audience       []string
 	JsonWebTokenID string
+	Scope          string
+	ClientID       string
+	// Actor is the party acting on behalf of the subject, it is set in the exchanged tokens.
+	Actor *Actor
+	// Confirmation is the key the token is bound to, as defined by RFC 7800.
+	Confirmation *Confirmation
+}
+
+type Confirmation struct {
+	// JWKThumbprint is the RFC 7638 thumbprint of the DPoP key.
+	JWKThumbprint string `json:"jkt"`
+}
+
+// Actor is the "act" claim defined by RFC 8693. The nested actor is the previous one in the delegation chain.
+type Actor struct {
+	Subject string
+	Actor   *Actor
 }
 
 type PublicClaims struct {
@@ -390,7 +578,9 @@ type PublicClaims struct {
 }
 
 type PrivateClaims struct {
-	// Not Defined by now
+	// Properties are the claims defined by the application, like roles or tenant IDs. The names of the registered and
+	// public claims cannot be used.
+	Properties map[string]interface{}
 }
 
 type VerifyInput struct {
@@ -399,6 +589,8 @@ type VerifyInput struct {
 
 type VerifyOutput struct {
 	ExpiredAt        time.Time
+	NotBefore        time.Time
+	IssuedAt         time.Time
 	RegisteredClaims *RegisteredClaims
 	PublicClaims     *PublicClaims
 	PrivateClaims    *PrivateClaims
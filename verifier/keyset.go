@@ -0,0 +1,288 @@
+package verifier
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("the signing key of the token is not available")
+
+// Key is a public key of the issuer.
+type Key struct {
+	ID        string
+	Algorithm string
+	// PublicKey is an ed25519.PublicKey, *ecdsa.PublicKey or *rsa.PublicKey.
+	PublicKey crypto.PublicKey
+}
+
+// KeySource returns the key with the given ID.
+type KeySource interface {
+	Key(keyID string) (*Key, error)
+}
+
+// defaultHTTPClient gives up on the issuers that do not answer, so a hung endpoint does not block the verifications.
+var defaultHTTPClient = &http.Client{Timeout: time.Second * 10}
+
+// RemoteKeySet fetches the keys from a JWKS endpoint and keeps them during the cache time. When a token references an
+// unknown key the set is fetched again, at most once per refresh interval, so the rotated keys are found without
+// flooding the issuer. The failed fetches are spaced by the same interval and the concurrent lookups share the same
+// fetch.
+type RemoteKeySet struct {
+	url                string
+	client             *http.Client
+	cacheTime          time.Duration
+	minRefreshInterval time.Duration
+	timeProvider       func() time.Time
+
+	keys      map[string]*Key
+	fetchedAt time.Time
+	// attemptedAt is the time of the last fetch, lastErr is its error.
+	attemptedAt time.Time
+	lastErr     error
+	// fetching is the fetch in progress, it is nil when there is none.
+	fetching *keySetFetch
+	mx       sync.Mutex
+}
+
+// keySetFetch is a fetch of the keys, done is closed when it finishes.
+type keySetFetch struct {
+	done chan struct{}
+}
+
+type RemoteKeySetOptions func(set *RemoteKeySet) error
+
+// HTTPClient sets the client used to fetch the keys, a client with a timeout of ten seconds is used by default.
+func HTTPClient(client *http.Client) RemoteKeySetOptions {
+	return func(set *RemoteKeySet) error {
+		if client == nil {
+			return errors.New("the HTTP client cannot be nil")
+		}
+
+		set.client = client
+		return nil
+	}
+}
+
+// CacheTime sets how long the keys are kept before fetching them again, one hour by default.
+func CacheTime(cacheTime time.Duration) RemoteKeySetOptions {
+	return func(set *RemoteKeySet) error {
+		if cacheTime <= 0 {
+			return errors.New("the cache time must be greater than zero")
+		}
+
+		set.cacheTime = cacheTime
+		return nil
+	}
+}
+
+// MinRefreshInterval sets the minimum time between two fetches caused by unknown keys, one minute by default.
+func MinRefreshInterval(interval time.Duration) RemoteKeySetOptions {
+	return func(set *RemoteKeySet) error {
+		if interval < 0 {
+			return errors.New("the refresh interval cannot be negative")
+		}
+
+		set.minRefreshInterval = interval
+		return nil
+	}
+}
+
+func NewRemoteKeySet(url string, opts ...RemoteKeySetOptions) (*RemoteKeySet, error) {
+	set := &RemoteKeySet{
+		url:                url,
+		client:             defaultHTTPClient,
+		cacheTime:          time.Hour,
+		minRefreshInterval: time.Minute,
+		timeProvider:       time.Now,
+		keys:               map[string]*Key{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(set); err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
+
+func (r *RemoteKeySet) Key(keyID string) (*Key, error) {
+	r.mx.Lock()
+	now := r.timeProvider()
+	key, known := r.keys[keyID]
+	stale := r.fetchedAt.IsZero() || !now.Before(r.fetchedAt.Add(r.cacheTime))
+	if known && !stale {
+		r.mx.Unlock()
+		return key, nil
+	}
+
+	call := r.fetching
+	if call == nil {
+		if !r.attemptedAt.IsZero() && now.Before(r.attemptedAt.Add(r.minRefreshInterval)) {
+			defer r.mx.Unlock()
+			return r.cachedKey(keyID)
+		}
+
+		call = &keySetFetch{done: make(chan struct{})}
+		r.fetching = call
+		r.attemptedAt = now
+		r.mx.Unlock()
+
+		// The issuer is called without holding the lock, the lookups of the known keys are not blocked.
+		keys, err := r.fetch()
+
+		r.mx.Lock()
+		if err == nil {
+			r.keys = keys
+			r.fetchedAt = now
+		}
+		r.lastErr = err
+		r.fetching = nil
+		close(call.done)
+	} else {
+		r.mx.Unlock()
+		<-call.done
+		r.mx.Lock()
+	}
+
+	defer r.mx.Unlock()
+	return r.cachedKey(keyID)
+}
+
+// cachedKey returns the given key from the last keys fetched. The cached keys are still trusted when the issuer is not
+// available, the unknown keys get the error of the last fetch.
+func (r *RemoteKeySet) cachedKey(keyID string) (*Key, error) {
+	if key, known := r.keys[keyID]; known {
+		return key, nil
+	}
+
+	if r.lastErr != nil {
+		return nil, r.lastErr
+	}
+
+	return nil, ErrUnknownKey
+}
+
+func (r *RemoteKeySet) fetch() (map[string]*Key, error) {
+	response, err := r.client.Get(r.url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the JWKS endpoint returned the status %d", response.StatusCode)
+	}
+
+	content := &struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{}
+	if err = json.NewDecoder(response.Body).Decode(content); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*Key{}
+	for _, raw := range content.Keys {
+		// The keys that cannot be read, like the encryption keys, are skipped.
+		if key, err := ParseJSONWebKey(raw); err == nil {
+			keys[key.ID] = key
+		}
+	}
+
+	return keys, nil
+}
+
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// ParseJSONWebKey reads a signature key as defined by RFC 7517. The keys without algorithm get the one that matches the
+// key type, the RSA keys get RS256.
+func ParseJSONWebKey(content []byte) (*Key, error) {
+	jwk := &jsonWebKey{}
+	if err := json.Unmarshal(content, jwk); err != nil {
+		return nil, err
+	}
+
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return nil, fmt.Errorf("the key %q is not a signature key", jwk.KeyID)
+	}
+
+	key := &Key{ID: jwk.KeyID, Algorithm: jwk.Algorithm}
+	switch jwk.KeyType {
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || jwk.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("the key %q is not a valid Ed25519 key", jwk.KeyID)
+		}
+
+		key.PublicKey = ed25519.PublicKey(x)
+		return withAlgorithm(key, "EdDSA")
+	case "EC":
+		curve, algorithm := ellipticCurve(jwk.Curve)
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if curve == nil || errX != nil || errY != nil {
+			return nil, fmt.Errorf("the key %q is not a valid ECDSA key", jwk.KeyID)
+		}
+
+		publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, fmt.Errorf("the key %q is not a valid ECDSA key", jwk.KeyID)
+		}
+
+		key.PublicKey = publicKey
+		return withAlgorithm(key, algorithm)
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("the key %q is not a valid RSA key", jwk.KeyID)
+		}
+
+		key.PublicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return withAlgorithm(key, "RS256")
+	default:
+		return nil, fmt.Errorf("the key type %q is not supported", jwk.KeyType)
+	}
+}
+
+func ellipticCurve(name string) (elliptic.Curve, string) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), "ES256"
+	case "P-384":
+		return elliptic.P384(), "ES384"
+	case "P-521":
+		return elliptic.P521(), "ES512"
+	default:
+		return nil, ""
+	}
+}
+
+func withAlgorithm(key *Key, algorithm string) (*Key, error) {
+	if key.Algorithm == "" {
+		key.Algorithm = algorithm
+	}
+
+	return key, nil
+}
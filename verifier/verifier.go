@@ -0,0 +1,282 @@
+// Package verifier validates the access tokens issued by the authentication pool. It fetches the keys from the JWKS
+// endpoint of the issuer, so the services that only need to read the tokens do not depend on the provider.
+package verifier
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/pascaldekloe/jwt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("the given token is not valid")
+
+var ErrExpiredToken = errors.New("the given token has expired")
+
+var ErrTokenNotValidYet = errors.New("the given token is not valid yet")
+
+var ErrTokenIssuedInFuture = errors.New("the given token has been issued in the future")
+
+var ErrInvalidIssuer = errors.New("the given token has been issued by another issuer")
+
+var ErrInvalidAudience = errors.New("the given token has been issued for another audience")
+
+var ErrBoundToken = errors.New("the given token is bound to a key and its proof has not been checked")
+
+// Claims are the claims of a verified token. The claims without field are in Extra.
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Audience      []string
+	ID            string
+	ExpiredAt     time.Time
+	NotBefore     time.Time
+	IssuedAt      time.Time
+	Scope         string
+	ClientID      string
+	Name          string
+	GivenName     string
+	FamilyName    string
+	Email         string
+	EmailVerified bool
+	Picture       string
+	// Confirmation is the key the token is bound to, it is nil for the bearer tokens.
+	Confirmation *Confirmation
+	Extra        map[string]interface{}
+}
+
+// Confirmation is the "cnf" claim defined by RFC 7800.
+type Confirmation struct {
+	// JWKThumbprint is the RFC 7638 thumbprint of the DPoP key, as defined by RFC 9449.
+	JWKThumbprint string
+}
+
+// Verifier checks the signature and the registered claims of the tokens.
+type Verifier struct {
+	issuer       string
+	audience     []string
+	keys         KeySource
+	leeway       time.Duration
+	timeProvider func() time.Time
+	acceptBound  bool
+}
+
+type Options func(verifier *Verifier) error
+
+// Leeway accepts tokens whose time claims are off by the given duration.
+func Leeway(leeway time.Duration) Options {
+	return func(verifier *Verifier) error {
+		if leeway < 0 {
+			return errors.New("the leeway cannot be negative")
+		}
+
+		verifier.leeway = leeway
+		return nil
+	}
+}
+
+// AcceptBoundTokens accepts the tokens bound to a key, like the DPoP tokens. They are rejected by default because a
+// stolen bound token would work as a bearer token, the caller must check the proof of the key in Claims.Confirmation.
+func AcceptBoundTokens() Options {
+	return func(verifier *Verifier) error {
+		verifier.acceptBound = true
+		return nil
+	}
+}
+
+// New creates a verifier of the tokens issued by the given issuer for at least one of the audiences. A verifier without
+// audience only accepts tokens without audience.
+func New(issuer string, audience []string, keys KeySource, opts ...Options) (*Verifier, error) {
+	verifier := &Verifier{
+		issuer:       issuer,
+		audience:     audience,
+		keys:         keys,
+		timeProvider: time.Now,
+	}
+
+	for _, opt := range opts {
+		if err := opt(verifier); err != nil {
+			return nil, err
+		}
+	}
+
+	return verifier, nil
+}
+
+// Verify checks the signature of the token with the key of its "kid" header, the algorithm of the header must be the
+// one of the key. Then it validates the issuer, the audience and the time claims, the expiration is required. The
+// tokens bound to a key return ErrBoundToken unless AcceptBoundTokens is set.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	header, err := parseHeader(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := v.keys.Key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Algorithm != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := check([]byte(token), key)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	result := readClaims(claims)
+	if err = v.validate(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (v *Verifier) validate(claims *Claims) error {
+	now := v.timeProvider()
+
+	if claims.Issuer != v.issuer {
+		return ErrInvalidIssuer
+	}
+
+	if !v.acceptAudience(claims.Audience) {
+		return ErrInvalidAudience
+	}
+
+	if !claims.NotBefore.IsZero() && now.Add(v.leeway).Before(claims.NotBefore) {
+		return ErrTokenNotValidYet
+	}
+
+	if !claims.IssuedAt.IsZero() && now.Add(v.leeway).Before(claims.IssuedAt) {
+		return ErrTokenIssuedInFuture
+	}
+
+	if claims.ExpiredAt.IsZero() || !now.Add(-v.leeway).Before(claims.ExpiredAt) {
+		return ErrExpiredToken
+	}
+
+	if claims.Confirmation != nil && !v.acceptBound {
+		return ErrBoundToken
+	}
+
+	return nil
+}
+
+func (v *Verifier) acceptAudience(audience []string) bool {
+	if len(v.audience) == 0 {
+		return len(audience) == 0
+	}
+
+	for _, expected := range v.audience {
+		for _, given := range audience {
+			if expected == given {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+func parseHeader(token string) (*header, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &header{}
+	if err = json.Unmarshal(content, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func check(token []byte, key *Key) (*jwt.Claims, error) {
+	switch publicKey := key.PublicKey.(type) {
+	case ed25519.PublicKey:
+		return jwt.EdDSACheck(token, publicKey)
+	case *ecdsa.PublicKey:
+		return jwt.ECDSACheck(token, publicKey)
+	case *rsa.PublicKey:
+		return jwt.RSACheck(token, publicKey)
+	default:
+		return nil, ErrUnknownKey
+	}
+}
+
+var namedClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "jti": true, "exp": true, "nbf": true, "iat": true, "scope": true,
+	"client_id": true, "name": true, "given_name": true, "family_name": true, "email": true, "email_verified": true,
+	"picture": true, "cnf": true,
+}
+
+func readClaims(claims *jwt.Claims) *Claims {
+	result := &Claims{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Audience:      claims.Audiences,
+		ID:            claims.ID,
+		ExpiredAt:     claims.Expires.Time(),
+		NotBefore:     claims.NotBefore.Time(),
+		IssuedAt:      claims.Issued.Time(),
+		Scope:         stringValue(claims.Set, "scope"),
+		ClientID:      stringValue(claims.Set, "client_id"),
+		Name:          stringValue(claims.Set, "name"),
+		GivenName:     stringValue(claims.Set, "given_name"),
+		FamilyName:    stringValue(claims.Set, "family_name"),
+		Email:         stringValue(claims.Set, "email"),
+		EmailVerified: boolValue(claims.Set, "email_verified"),
+		Picture:       stringValue(claims.Set, "picture"),
+		Extra:         map[string]interface{}{},
+	}
+
+	if cnf, ok := claims.Set["cnf"].(map[string]interface{}); ok {
+		result.Confirmation = &Confirmation{JWKThumbprint: stringValue(cnf, "jkt")}
+	}
+
+	for name, value := range claims.Set {
+		if !namedClaims[name] {
+			result.Extra[name] = value
+		}
+	}
+
+	return result
+}
+
+func stringValue(input map[string]interface{}, key string) string {
+	if s, ok := input[key].(string); ok {
+		return s
+	}
+
+	return ""
+}
+
+// boolValue reads a boolean claim, some issuers send them as strings.
+func boolValue(input map[string]interface{}, key string) bool {
+	switch v := input[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
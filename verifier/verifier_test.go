@@ -0,0 +1,318 @@
+package verifier
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pascaldekloe/jwt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testIssuer struct {
+	server   *httptest.Server
+	keys     []map[string]string
+	requests int32
+}
+
+func newTestIssuer() *testIssuer {
+	issuer := &testIssuer{}
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&issuer.requests, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": issuer.keys})
+	}))
+
+	return issuer
+}
+
+func (i *testIssuer) addEdDSAKey(keyID string) ed25519.PrivateKey {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	i.keys = append(i.keys, map[string]string{
+		"kty": "OKP", "crv": "Ed25519", "kid": keyID, "alg": "EdDSA", "use": "sig",
+		"x": base64.RawURLEncoding.EncodeToString(publicKey),
+	})
+	return privateKey
+}
+
+func (i *testIssuer) addECDSAKey(keyID string) *ecdsa.PrivateKey {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	i.keys = append(i.keys, map[string]string{
+		"kty": "EC", "crv": "P-256", "kid": keyID,
+		"x": base64.RawURLEncoding.EncodeToString(padBytes(privateKey.X.Bytes(), 32)),
+		"y": base64.RawURLEncoding.EncodeToString(padBytes(privateKey.Y.Bytes(), 32)),
+	})
+	return privateKey
+}
+
+func padBytes(content []byte, size int) []byte {
+	return append(make([]byte, size-len(content)), content...)
+}
+
+func testClaims(keyID string, now time.Time) *jwt.Claims {
+	return &jwt.Claims{
+		KeyID: keyID,
+		Registered: jwt.Registered{
+			Issuer:    "https://auth.example.com",
+			Subject:   "customer",
+			Audiences: []string{"orders"},
+			ID:        "token-id",
+			Issued:    jwt.NewNumericTime(now),
+			NotBefore: jwt.NewNumericTime(now),
+			Expires:   jwt.NewNumericTime(now.Add(time.Minute * 10)),
+		},
+		Set: map[string]interface{}{"email": "customer@example.com", "scope": "orders:read", "tenant_id": "acme"},
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	issuer := newTestIssuer()
+	defer issuer.server.Close()
+
+	edKey := issuer.addEdDSAKey("ed")
+	ecKey := issuer.addECDSAKey("ec")
+
+	keys, err := NewRemoteKeySet(issuer.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.timeProvider = func() time.Time { return now }
+
+	v, err := New("https://auth.example.com", []string{"orders"}, keys, Leeway(time.Second*30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.timeProvider = func() time.Time { return now }
+
+	sign := func(claims *jwt.Claims, algorithm string) string {
+		var (
+			token []byte
+			err   error
+		)
+		switch algorithm {
+		case jwt.EdDSA:
+			token, err = claims.EdDSASign(edKey)
+		case jwt.ES256:
+			token, err = claims.ECDSASign(jwt.ES256, ecKey)
+		case jwt.HS256:
+			token, err = claims.HMACSign(jwt.HS256, []byte("a secret known by the attacker"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(token)
+	}
+
+	t.Run("returns the claims", func(t *testing.T) {
+		for _, token := range []string{sign(testClaims("ed", now), jwt.EdDSA), sign(testClaims("ec", now), jwt.ES256)} {
+			got, err := v.Verify(token)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			if got.Subject != "customer" || got.Email != "customer@example.com" || got.Scope != "orders:read" ||
+				got.Extra["tenant_id"] != "acme" || !got.ExpiredAt.Equal(now.Add(time.Minute*10)) {
+				t.Errorf("Verify() got = %v", got)
+			}
+		}
+	})
+
+	tests := []struct {
+		name      string
+		token     func() string
+		wantError error
+	}{
+		{
+			name: "another issuer",
+			token: func() string {
+				c := testClaims("ed", now)
+				c.Issuer = "https://evil.example.com"
+				return sign(c, jwt.EdDSA)
+			},
+			wantError: ErrInvalidIssuer,
+		},
+		{
+			name: "another audience",
+			token: func() string {
+				c := testClaims("ed", now)
+				c.Audiences = []string{"billing"}
+				return sign(c, jwt.EdDSA)
+			},
+			wantError: ErrInvalidAudience,
+		},
+		{
+			name:      "expired",
+			token:     func() string { return sign(testClaims("ed", now.Add(-time.Minute*11)), jwt.EdDSA) },
+			wantError: ErrExpiredToken,
+		},
+		{
+			name:      "expired in the leeway",
+			token:     func() string { return sign(testClaims("ed", now.Add(-time.Minute*10).Add(time.Second)), jwt.EdDSA) },
+			wantError: nil,
+		},
+		{
+			name: "not valid yet",
+			token: func() string {
+				c := testClaims("ed", now)
+				c.NotBefore = jwt.NewNumericTime(now.Add(time.Minute))
+				return sign(c, jwt.EdDSA)
+			},
+			wantError: ErrTokenNotValidYet,
+		},
+		{
+			name: "without expiration",
+			token: func() string {
+				c := testClaims("ed", now)
+				c.Expires = nil
+				return sign(c, jwt.EdDSA)
+			},
+			wantError: ErrExpiredToken,
+		},
+		{
+			name: "bound to a key",
+			token: func() string {
+				c := testClaims("ed", now)
+				c.Set["cnf"] = map[string]interface{}{"jkt": "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I"}
+				return sign(c, jwt.EdDSA)
+			},
+			wantError: ErrBoundToken,
+		},
+		{
+			name:      "algorithm of another key",
+			token:     func() string { return sign(testClaims("ec", now), jwt.HS256) },
+			wantError: ErrInvalidToken,
+		},
+		{
+			name:      "signed by another key",
+			token:     func() string { return sign(testClaims("ec", now), jwt.EdDSA) },
+			wantError: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.token()); err != tt.wantError {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantError)
+			}
+		})
+	}
+
+	t.Run("returns the key of the bound tokens", func(t *testing.T) {
+		bound, err := New("https://auth.example.com", []string{"orders"}, keys, AcceptBoundTokens())
+		if err != nil {
+			t.Fatal(err)
+		}
+		bound.timeProvider = func() time.Time { return now }
+
+		c := testClaims("ed", now)
+		c.Set["cnf"] = map[string]interface{}{"jkt": "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I"}
+		got, err := bound.Verify(sign(c, jwt.EdDSA))
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+
+		if got.Confirmation == nil || got.Confirmation.JWKThumbprint != "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I" {
+			t.Errorf("Verify() confirmation = %v", got.Confirmation)
+		}
+		if _, ok := got.Extra["cnf"]; ok {
+			t.Errorf("Verify() got the cnf claim in Extra")
+		}
+	})
+}
+
+func TestRemoteKeySet_Key(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	issuer := newTestIssuer()
+	defer issuer.server.Close()
+	issuer.addEdDSAKey("first")
+
+	keys, err := NewRemoteKeySet(issuer.server.URL, CacheTime(time.Hour), MinRefreshInterval(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.timeProvider = func() time.Time { return now }
+
+	if _, err = keys.Key("first"); err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+	if _, err = keys.Key("first"); err != nil || issuer.requests != 1 {
+		t.Errorf("Key() error = %v, requests = %d, want the cached key", err, issuer.requests)
+	}
+
+	issuer.addEdDSAKey("second")
+	if _, err = keys.Key("second"); err != ErrUnknownKey || issuer.requests != 1 {
+		t.Errorf("Key() error = %v, requests = %d, want no refresh before the interval", err, issuer.requests)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err = keys.Key("second"); err != nil || issuer.requests != 2 {
+		t.Errorf("Key() error = %v, requests = %d, want a refresh for the unknown key", err, issuer.requests)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err = keys.Key("first"); err != nil || issuer.requests != 3 {
+		t.Errorf("Key() error = %v, requests = %d, want a refresh of the stale keys", err, issuer.requests)
+	}
+}
+
+func TestRemoteKeySet_KeyUnavailableIssuer(t *testing.T) {
+	now := time.Date(1974, 12, 4, 6-5, 0, 0, 0, time.UTC)
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	keys, err := NewRemoteKeySet(server.URL, MinRefreshInterval(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.timeProvider = func() time.Time { return now }
+
+	// The concurrent lookups wait for the same fetch.
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := keys.Key("unknown")
+			errs <- err
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	for i := 0; i < 10; i++ {
+		if err = <-errs; err == nil || err == ErrUnknownKey {
+			t.Errorf("Key() error = %v, want the fetch error", err)
+		}
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, err = keys.Key("unknown"); err == nil {
+			t.Fatalf("Key() error = nil")
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Key() requests = %d, want 1 before the refresh interval", got)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err = keys.Key("unknown"); err == nil || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("Key() error = %v, requests = %d, want a new fetch after the interval", err, requests)
+	}
+}